@@ -0,0 +1,269 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+//募资结构
+
+import (
+	"errors"
+	"fmt"
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/xyjxyjxyj/MySC/common"
+	"github.com/xyjxyjxyj/MySC/money"
+)
+
+// SimpleChaincode example simple Chaincode implementation
+type SimpleChaincode struct {
+}
+
+//募资结构结构体，字段名与原来的全局键名一致
+type fundRaisingStruct struct {
+	FundRaisingID string `json:"fundRaisingID"`	//募资结构编号
+	Sum money.Money `json:"Sum"`	//计划募资总金额
+	Prority1 string `json:"Prority1"`	//第一顺位（json字符串）
+	Prority2 string `json:"Prority2"`	//第二顺位
+	Prority3 string `json:"Prority3"`	//第三顺位
+}
+
+//募资结构以募资结构编号为组合键的属性存储，一个链码可以管理多个募资结构
+const keyFundRaising = "FundRaising"
+
+//初始化的时候传入参数有1个：操作人编号；
+//或者6个：募资结构编号，计划募资总金额，第一顺位（json字符串），第二顺位，第三顺位，操作人编号。顺序以这个为准。此时同时创建第一个募资结构，与原来的部署方式兼容
+//部署者的证书指纹记为管理员，只有管理员可以设置机构注册链码
+func (t *SimpleChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	return common.Respond(t.deploy(stub, args))
+}
+
+func (t *SimpleChaincode) deploy(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	err := common.CheckArgs(args, 1, 6)
+	if err != nil {
+		return nil, err
+	}
+
+	//部署时还没有设置机构注册链码，不校验权限
+	if len(args) == 6 {
+		_, err := t.create(stub, args)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	//记录管理员和操作人
+	return nil, common.RecordInit(stub, args)
+}
+
+//权限表中的函数修改账本，其他函数为查询
+func (t *SimpleChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	return common.Invoke(stub, permissions, t.invoke, t.query)
+}
+
+//修改账本的函数，调用前已经校验过权限，成功后记录操作
+func (t *SimpleChaincode) invoke(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+	if function == "create" {
+		return t.create(stub, args)
+	}else if function == "update" {
+		return t.update(stub, args)
+	}else if function == "setOrgRegistry" {
+		return common.SetOrgRegistry(stub, args)
+	}
+
+	return nil, errors.New("no such a method on this chaincode")
+}
+
+//将6个参数转换成募资结构：募资结构编号，计划募资总金额，第一顺位（json字符串），第二顺位，第三顺位，操作人编号
+func parseFundRaising(args []string) (fundRaisingStruct, error) {
+	var fundRaising fundRaisingStruct
+	var err error
+
+	err = common.CheckArgs(args, 6)
+	if err != nil {
+		return fundRaising, err
+	}
+
+	fundRaising.FundRaisingID = args[0]
+	fundRaising.Sum, err = money.Parse(args[1])
+	if err != nil {
+		return fundRaising, errors.New("The sum " + args[1] + " is not a valid amount")
+	}
+	fundRaising.Prority1 = args[2]
+	fundRaising.Prority2 = args[3]
+	fundRaising.Prority3 = args[4]
+
+	if fundRaising.FundRaisingID == "" {
+		return fundRaising, errors.New("The fundRaisingID is empty")
+	}
+	return fundRaising, nil
+}
+
+func getFundRaising(stub shim.ChaincodeStubInterface, fundRaisingID string) (*fundRaisingStruct, error) {
+	var fundRaising fundRaisingStruct
+
+	key, err := common.CreateCompositeKey(stub, keyFundRaising, fundRaisingID)
+	if err != nil {
+		return nil, err
+	}
+	found, err := common.GetJSON(stub, key, &fundRaising)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &fundRaising, nil
+}
+
+func putFundRaising(stub shim.ChaincodeStubInterface, fundRaising fundRaisingStruct) error {
+	key, err := common.CreateCompositeKey(stub, keyFundRaising, fundRaising.FundRaisingID)
+	if err != nil {
+		return err
+	}
+	return common.PutJSON(stub, key, fundRaising)
+}
+
+//新建募资结构传入参数有6个：募资结构编号，计划募资总金额，第一顺位（json字符串），第二顺位，第三顺位，操作人编号。
+func (t *SimpleChaincode) create(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fundRaising, err := parseFundRaising(args)
+	if err != nil {
+		return nil, err
+	}
+
+	//募资结构编号不能重复
+	oldFundRaising, err := getFundRaising(stub, fundRaising.FundRaisingID)
+	if err != nil {
+		return nil, err
+	}
+	if oldFundRaising != nil {
+		return nil, errors.New("The fundRaising " + fundRaising.FundRaisingID + " already exists")
+	}
+
+	return nil, putFundRaising(stub, fundRaising)
+}
+
+//更新募资结构传入参数有6个：募资结构编号，计划募资总金额，第一顺位（json字符串），第二顺位，第三顺位，操作人编号。
+//募资结构编号只用于定位要更新的募资结构
+func (t *SimpleChaincode) update(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fundRaising, err := parseFundRaising(args)
+	if err != nil {
+		return nil, err
+	}
+
+	oldFundRaising, err := getFundRaising(stub, fundRaising.FundRaisingID)
+	if err != nil {
+		return nil, err
+	}
+	if oldFundRaising == nil {
+		return nil, errors.New("The fundRaising " + fundRaising.FundRaisingID + " does not exist")
+	}
+
+	return nil, putFundRaising(stub, fundRaising)
+}
+
+//权限表：函数名 → 允许调用的机构角色，setOrgRegistry只能由管理员调用一次
+var permissions = map[string][]string{
+	"create": {common.RolePartnership, common.RoleSPV},
+	"update": {common.RolePartnership, common.RoleSPV},
+	"setOrgRegistry": {common.RoleAdmin},
+}
+
+//募资结构查询结果
+type fundRaisingDocument struct {
+	SchemaVersion int 	//查询结果格式版本
+	FundRaisingID string 	//募资结构编号
+	Sum money.Money 	//计划募资总金额
+	Priorities []json.RawMessage 	//第一、二、三顺位，按顺序；录入的是json时原样返回，否则作为json字符串返回
+}
+
+//把保存的募资结构转换成查询结果
+func newFundRaisingDocument(fundRaising fundRaisingStruct) fundRaisingDocument {
+	return fundRaisingDocument{
+		SchemaVersion: common.QuerySchemaVersion,
+		FundRaisingID: fundRaising.FundRaisingID,
+		Sum: fundRaising.Sum,
+		Priorities: []json.RawMessage{common.RawJSON(fundRaising.Prority1), common.RawJSON(fundRaising.Prority2), common.RawJSON(fundRaising.Prority3)},
+	}
+}
+
+//查询，与修改账本的函数一样通过Invoke调用
+//getFundRaising 传入参数有1个：募资结构编号，返回募资结构
+//listFundRaisings 没有参数，返回所有募资结构（json数组）
+func (t *SimpleChaincode) query(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+	if function == "getFundRaising" {
+		return t.getFundRaising(stub, args)
+	}else if function == "listFundRaisings" {
+		return t.listFundRaisings(stub, args)
+	}
+
+	return nil, errors.New("Invalid query function name. Expecting \"getFundRaising\" or \"listFundRaisings\"")
+}
+
+func (t *SimpleChaincode) getFundRaising(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) != 1 {
+		return nil, errors.New("Incorrect number of arguments. Expecting fundRaisingID to query")
+	}
+
+	fundRaising, err := getFundRaising(stub, args[0])
+	if err != nil {
+		jsonResp := "{\"Error\":\"Failed to get state for " + args[0] + "\"}"
+		return nil, errors.New(jsonResp)
+	}
+	if fundRaising == nil {
+		jsonResp := "{\"Error\":\"Nil fundRaising for " + args[0] + "\"}"
+		return nil, errors.New(jsonResp)
+	}
+
+	return json.Marshal(newFundRaisingDocument(*fundRaising))
+}
+
+func (t *SimpleChaincode) listFundRaisings(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	var documents []fundRaisingDocument
+
+	err := common.CheckArgs(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := stub.GetStateByPartialCompositeKey(keyFundRaising, []string{})
+	if err != nil {
+		return nil, errors.New("Failed to get state")
+	}
+	defer iter.Close()
+
+	documents = []fundRaisingDocument{}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		var fundRaising fundRaisingStruct
+		err = json.Unmarshal(kv.Value, &fundRaising)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, newFundRaisingDocument(fundRaising))
+	}
+
+	return json.Marshal(documents)
+}
+
+func main() {
+	err := shim.Start(new(SimpleChaincode))
+	if err != nil {
+		fmt.Printf("Error starting Simple chaincode: %s", err)
+	}
+}
@@ -0,0 +1,2532 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+
+//数字汇票
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"strconv"
+	"encoding/csv"
+	"encoding/json"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/xyjxyjxyj/MySC/common"
+	"github.com/xyjxyjxyj/MySC/money"
+)
+
+// SimpleChaincode example simple Chaincode implementation
+type SimpleChaincode struct {
+}
+
+//数字汇票路径节点信息结构体
+//日期格式为YYYYMMDD，计划路径中为转账截止日期，实际路径中为交易日期（北京时间）
+type InfoStruct struct {
+	Account string 	//账户
+	Time string 	//转账截止日期
+	BankTime string `json:",omitempty"`	//银行流水中的转账时间，只在实际路径中记录
+	Overdue bool `json:",omitempty"`	//是否逾期
+	DaysLate int `json:",omitempty"`	//逾期天数
+	Source string `json:",omitempty"`	//实际路径节点来源：空为银行流水确认，MANUAL为手工平账
+	ReasonCode string `json:",omitempty"`	//手工平账原因码
+	Note string `json:",omitempty"`	//手工平账说明
+	Requester string `json:",omitempty"`	//手工平账申请人
+	Approver string `json:",omitempty"`	//手工平账复核人，冲正时为第二个同意的机构
+	ReversedTxID string `json:",omitempty"`	//冲正节点被冲正的交易ID
+}
+
+//实际路径节点来源
+const (
+	pathSourceManual = "MANUAL"	//手工平账
+	pathSourceReversal = "REVERSAL"	//冲正，抵销被冲正的交易加入的节点
+)
+
+//数字汇票信息结构体
+type draftInfoStruct struct {
+	Sum money.Money 	//数字汇票金额
+	Initiator string 	//发行机构ID
+	Target string 	//最终到账机构ID
+	Owner string 	//汇票所属机构ID
+	PlanPath []InfoStruct 	//计划路径
+	TruePath []InfoStruct 	//实际路径
+	Status string 	//状态码，见statusIssued等，原来以空字符串或不匹配原因保存的状态读出时转换
+	MismatchCode string `json:",omitempty"`	//不匹配码，只在Mismatch状态下有值
+	MismatchReason string `json:",omitempty"`	//不匹配原因
+	Paid money.Money 	//计划路径当前节点已付金额，部分付款时累计，该节点转账完成后清零
+	Outstanding money.Money 	//计划路径当前节点未付金额
+	GroupID string 	//所属汇票组ID，发行时设置为汇票ID的前八位
+	Route string 	//路由模板名称，发行时不填写则使用发行机构角色对应的默认模板
+	ProjectID string `json:",omitempty"`	//关联的项目ID，汇票过期时标记该项目的资金进度
+}
+
+//部署时，传入参数有1个：操作人编号
+//部署者的证书指纹记为管理员，只有管理员可以设置机构注册链码
+func (t *SimpleChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	return common.Respond(t.deploy(stub, args))
+}
+
+func (t *SimpleChaincode) deploy(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	err := common.CheckArgs(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	//记录管理员和操作人
+	return nil, common.RecordInit(stub, args)
+}
+
+//权限表中的函数修改账本，其他函数为查询
+func (t *SimpleChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	return common.Invoke(stub, permissions, t.invoke, t.query)
+}
+
+//修改账本的函数，调用前已经校验过权限，成功后记录操作
+func (t *SimpleChaincode) invoke(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+	if function == "transfer" {
+		return t.transfer(stub, args)
+	}else if function == "create" {
+		return t.create(stub, args)
+	}else if function == "update" {
+		return t.update(stub, args)
+	}else if function == "setOrgRegistry" {
+		return common.SetOrgRegistry(stub, args)
+	}else if function == "setRouteTemplate" {
+		return t.setRouteTemplate(stub, args)
+	}else if function == "settle" {
+		return t.settle(stub, args)
+	}else if function == "cancel" {
+		return t.cancel(stub, args)
+	}else if function == "approveUpdate" {
+		return t.approveUpdate(stub, args)
+	}else if function == "reverseTransfer" {
+		return t.reverseTransfer(stub, args)
+	}else if function == "expireOverdue" {
+		return t.expireOverdue(stub, args)
+	}else if function == "setProjectChaincode" {
+		return t.setProjectChaincode(stub, args)
+	}else if function == "reindexDrafts" {
+		return t.reindexDrafts(stub, args)
+	}else if function == "reconcileBatch" {
+		return t.reconcileBatch(stub, args)
+	}
+
+	return nil, errors.New("no such a method on this chaincode")
+}
+
+
+//发行数字汇票 参数有3个，一个是数字汇票ID，一个数字汇票信息（json字符串，其中包含的属性有：金额，发行机构ID，最终到账机构ID，当前所属机构ID，计划路径，实际路径，路由模板名称，关联的项目ID），操作人
+//数字汇票ID规则设定：九位阿拉伯数字，前八位为大汇票表示，最后一位选择1.2.3,1表示县发行，2表示省发行，3表示ICBC发行。例子：123456781县 123456782省 123456783ICBC
+//机构ID：县101+县ID 省20003 ICBC20006 有限合伙20005 SPV102+县ID 项目公司3+xxx，机构的角色通过机构注册链码查询
+func (t *SimpleChaincode) create(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	var draftID string	//数字汇票ID
+	var draftInfo draftInfoStruct	//数字汇票信息结构体
+	var draftInfoByte []byte 	//接收汇票信息查询结果
+
+	var err error
+
+	err = common.CheckArgs(args, 3)
+	if err != nil {
+		return nil, err
+	}
+
+	// Initialize the chaincode
+	draftID = args[0]
+
+	//校验汇票ID：九位数字，最后一位为1、2、3
+	if !isDraftID(draftID) {
+		return nil, common.NewError(errInvalidDraftID, "The draftID " + draftID + " must be 9 digits ending with 1, 2 or 3")
+	}
+
+	//将json字符串的汇票信息转换成struct
+	err = json.Unmarshal([]byte(args[1]), &draftInfo)
+	if err == money.ErrInvalid || err == money.ErrOverflow {
+		return nil, common.NewError(errInvalidSum, "The sum is not a valid amount: " + err.Error())
+	}
+	if err != nil {
+		return nil, common.NewError(errInvalidDraftInfo, "The draft information is not valid json: " + err.Error())
+	}
+
+	err = validateDraftInfo(stub, draftID, &draftInfo)
+	if err != nil {
+		return nil, err
+	}
+	//汇票只能由发行机构自己发行
+	if args[2] != draftInfo.Initiator {
+		return nil, errors.New("The operator " + args[2] + " is not the initiator " + draftInfo.Initiator)
+	}
+
+	//汇票ID不能重复
+	draftInfoByte, err = stub.GetState(draftID)
+	if err != nil {
+		return nil, errors.New("Failed to get state")
+	}
+	if draftInfoByte != nil {
+		return nil, common.NewError(errDraftExists, "The draft " + draftID + " already exists")
+	}
+
+	//加入汇票组，组内第一张汇票发行时创建汇票组
+	draftInfo.GroupID = groupIDOf(draftID)
+	draftInfo.Status = statusIssued
+	draftInfo.MismatchCode = ""
+	draftInfo.MismatchReason = ""
+	resetPayment(&draftInfo)
+	err = addGroupMember(stub, draftID, draftInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	//汇票信息校验完毕，将汇票信息存进区块链中
+	err = putDraftInfo(stub, draftID, nil, draftInfo)
+	if err != nil {
+		return nil, err
+	}
+	err = recordHistory(stub, draftID, "create", args[2], "", draftInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	//发出发行事件
+	err = emitDraftEvent(stub, draftEvent{Type: eventDraftCreated, Drafts: []string{draftID}, NewOwner: draftInfo.Owner})
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+//数字汇票所有者转移 传入参数有7个：汇票ID，汇票owner变更由谁变到谁（newOwner），转账金额，转账账户，收款账户，转账时间，操作者编号
+//转账时间为银行流水中的时间，只记录在实际路径中；是否逾期用交易时间判断
+//比较的时候比4点，1.金额 2.时间 3.出账账户 4.到账账户 后三个都是在路径中判断的
+//一笔汇票可以分多次付款，累计金额与应付金额的差在路由模板的手续费容差内时该节点转账完成，不足时记为部分付款，所属机构不变
+//返回转账结果（json字符串），不匹配时也要保存不匹配状态，所以不返回错误，由调用方根据Matched判断
+func (t *SimpleChaincode) transfer(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	err := common.CheckArgs(args, 7)
+	if err != nil {
+		return nil, err
+	}
+
+	result, event, err := applyTransfer(stub, args[0], args[1], args[2], args[3], args[4], args[5], args[6], nil)
+	if err != nil {
+		return nil, err
+	}
+
+	//发出转账、部分付款、逾期或不匹配事件
+	err = emitDraftEvent(stub, event)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(result)
+}
+
+//执行一笔转账并返回转账结果和要发出的事件，由transfer和reconcileBatch调用，事件由调用方发出
+//参数：汇票ID，变更后所属机构ID，转账金额，转账账户，收款账户，银行流水中的转账时间，操作者编号，批量对账时的批次（transfer为nil）
+func applyTransfer(stub shim.ChaincodeStubInterface, draftID string, newOwnerID string, Sum string, payAccount string, receiptAccount string, bankTime string, operator string, batch *batchState) (transferResult, draftEvent, error) {
+	var drafts []trancheDraft 	//本次转账涉及的所有汇票，第一张为draftID
+	var group *draftGroupStruct 	//draftID所属的汇票组
+	var draftOwner string 	//数字汇票当前所属机构
+	var draftPayAccount string 	//数字汇票付款账号
+	var draftReceiptAccount string 	//数字汇票收款账号
+	var draftPayTime string 	//数字汇票转账时间
+	var truePathInfo InfoStruct 	//实际路径该节点的账户和实际转账时间信息结构体
+	var totleSum money.Money 	//涉及汇票的总金额
+	var paidSum money.Money 	//涉及汇票本节点累计已付金额，包括本次付款
+	var partial bool 	//是否部分付款
+	var mismatchCode string 	//不匹配码，取第一个不匹配的检查
+	var statusInfo string 	//不匹配原因
+	var result transferResult 	//转账结果
+	var event draftEvent 	//要发出的事件
+
+	var err error
+
+	//判断规则：
+	//判断点有：1.金额 2.时间 3.出账账户 4.到账账户
+	//不需要再判断这张汇票是谁发的，只需要判断这张汇票现阶段属于谁。
+	//如果属于20003或者200006或者101xx 则判断金额，出入账账户，出账时间，如果前三个都对只是时间不对，作出标识，但是依然平账，即记录到实际路径中，但是前三个对不上就不能平账
+	//如果属于20005，则是对汇票组中省、ICBC发行的两张汇票的操作，金额为两张的加和，出入账账户、时间两张汇票是一样的，所以选取draftID的信息为准，平账时同时更新两张汇票的实际路径
+	//如果属于102xx，则是对汇票组中三张汇票的操作，金额为三张的加和，出入账账户、时间三张汇票是一样的，所以选取draftID的信息为准，平账时同时更新三张汇票的实际路径
+	//涉及多张汇票时，先读出并校验所有汇票，再统一写入，任何一张不满足条件整个转账失败
+
+	group, drafts, err = loadTranche(stub, draftID, operator, batch)
+	if err != nil {
+		return result, event, err
+	}
+	draftOwner = drafts[0].PrevOwner
+	err = checkTransition(drafts, "transfer")
+	if err != nil {
+		return result, event, err
+	}
+
+	//ICBC流水信息的金额，格式不正确时直接报错，不能当成0去比较
+	SumValue, err := money.Parse(Sum)
+	if err != nil {
+		return result, event, common.NewError(errInvalidSum, "The sum " + Sum + " is not a valid amount")
+	}
+
+	//金额为涉及的所有汇票金额的加和
+	totleSum = money.New(0, drafts[0].Info.Sum.Currency)
+	for _, draft := range drafts {
+		totleSum, err = totleSum.Add(draft.Info.Sum)
+		if err != nil {
+			return result, event, common.NewError(errInvalidSum, "The sum of draft " + draft.ID + " can not be added: " + err.Error())
+		}
+	}
+
+	//出入账账户、时间涉及的所有汇票是一样的，loadTranche已经校验过，以draftID的计划路径为准
+	draftPayAccount = drafts[0].Info.PlanPath[drafts[0].Index].Account
+	draftReceiptAccount = drafts[0].Info.PlanPath[drafts[0].Index + 1].Account
+	draftPayTime = drafts[0].Info.PlanPath[drafts[0].Index].Time
+
+	//判断金额是否在容差内，出账账户、收款账户是否是同一个账户，三项都检查，结果中返回所有不匹配的项
+	//容差以draftID的路由模板为准
+	result.Drafts = trancheIDs(drafts)
+	result.PrevOwner = draftOwner
+	paidSum = money.New(0, totleSum.Currency)
+	for _, draft := range drafts {
+		paidSum, err = paidSum.Add(draft.Info.Paid)
+		if err != nil {
+			return result, event, common.NewError(errInvalidSum, "The paid amount of draft " + draft.ID + " can not be added: " + err.Error())
+		}
+	}
+	amountMatched, partial, paidSum := matchAmount(totleSum, paidSum, SumValue, drafts[0].Route.FeeTolerance)
+	if !amountMatched {
+		result.FailedChecks = append(result.FailedChecks, mismatchAmount)
+	}
+	if !strings.EqualFold(draftPayAccount, payAccount) {
+		result.FailedChecks = append(result.FailedChecks, mismatchPayAccount)
+	}
+	if !strings.EqualFold(draftReceiptAccount, receiptAccount) {
+		result.FailedChecks = append(result.FailedChecks, mismatchReceiptAccount)
+	}
+	if len(result.FailedChecks) > 0 {
+		mismatchCode = result.FailedChecks[0]
+	}
+
+	//前三个对不上就不能平账，在涉及的每一张汇票上记录不匹配码和原因
+	if mismatchCode != "" {
+		statusInfo = mismatchReasons[mismatchCode]
+		for i := range drafts {
+			drafts[i].Info.Status = statusMismatch
+			drafts[i].Info.MismatchCode = mismatchCode
+			drafts[i].Info.MismatchReason = statusInfo
+		}
+		err = putTranche(stub, group, drafts, "status", operator, batch)
+		if err != nil {
+			return result, event, err
+		}
+
+		//不匹配事件
+		event = draftEvent{Type: eventDraftMismatch, Drafts: trancheIDs(drafts), PrevOwner: draftOwner, NewOwner: draftOwner, Reason: statusInfo, MismatchCode: mismatchCode}
+
+		result.NewOwner = draftOwner
+		result.Status = statusMismatch
+		result.Reason = statusInfo
+		return result, event, nil
+	}
+
+	//部分付款，本次付款按顺序计入各张汇票的已付金额，所属机构不变
+	if partial {
+		err = allocatePayment(drafts, SumValue)
+		if err != nil {
+			return result, event, err
+		}
+		for i := range drafts {
+			drafts[i].Info.Status = statusPartiallyPaid
+			drafts[i].Info.MismatchCode = ""
+			drafts[i].Info.MismatchReason = ""
+		}
+		err = putTranche(stub, group, drafts, "payment", operator, batch)
+		if err != nil {
+			return result, event, err
+		}
+
+		//部分付款事件
+		event = draftEvent{Type: eventDraftPartiallyPaid, Drafts: trancheIDs(drafts), PrevOwner: draftOwner, NewOwner: draftOwner}
+
+		result.Matched = true
+		result.Partial = true
+		result.NewOwner = draftOwner
+		result.Status = statusPartiallyPaid
+		result.Paid = paidSum
+		result.Outstanding, err = totleSum.Sub(paidSum)
+		return result, event, err
+	}
+
+	//变更所属机构前校验新的所属机构，参数错误时返回错误，不记录不匹配
+	err = checkNewOwner(stub, drafts[0], newOwnerID, receiptAccount)
+	if err != nil {
+		return result, event, err
+	}
+
+	//用交易时间判断是否逾期，银行流水中的转账时间只做记录
+	truePathInfo, err = newTruePathInfo(stub, payAccount, draftPayTime, bankTime)
+	if err != nil {
+		return result, event, err
+	}
+
+	for i := range drafts {
+		//将实际路径节点信息加到汇票信息中去
+		drafts[i].Info.TruePath = append(drafts[i].Info.TruePath, truePathInfo)
+		//变更汇票所属人，清除之前的不匹配原因，下一个节点重新累计付款
+		drafts[i].Info.Owner = newOwnerID
+		drafts[i].Info.Status = statusInTransit
+		drafts[i].Info.MismatchCode = ""
+		drafts[i].Info.MismatchReason = ""
+		resetPayment(&drafts[i].Info)
+	}
+
+	//汇票信息变更完毕，将所有汇票信息重新存进区块链中
+	err = putTranche(stub, group, drafts, "transfer", operator, batch)
+	if err != nil {
+		return result, event, err
+	}
+
+	//转账事件，转账时间晚于计划时间时为逾期事件
+	event = draftEvent{Type: eventDraftTransferred, Drafts: trancheIDs(drafts), PrevOwner: draftOwner, NewOwner: newOwnerID}
+	if truePathInfo.Overdue {
+		event.Type = eventDraftOverdue
+		event.Overdue = true
+	}
+
+	result.Matched = true
+	result.NewOwner = newOwnerID
+	result.Status = statusInTransit
+	result.Overdue = truePathInfo.Overdue
+	result.DaysLate = truePathInfo.DaysLate
+	result.Paid = paidSum
+	result.Outstanding, err = totleSum.Sub(paidSum)
+	return result, event, err
+}
+
+//按容差比较本节点累计付款与应付金额，返回是否匹配、是否部分付款和包括本次付款的累计金额
+//本次付款不是正数或累计超出应付金额加容差为不匹配；累计不足应付金额减容差为部分付款
+func matchAmount(due money.Money, paid money.Money, payment money.Money, tolerance money.Money) (bool, bool, money.Money) {
+	//没有设置容差时按汇票币种的0处理
+	if tolerance.Minor == 0 {
+		tolerance = money.New(0, due.Currency)
+	}
+	total, err := paid.Add(payment)
+	if err != nil || !payment.IsPositive() {
+		return false, false, paid
+	}
+	upper, err := due.Add(tolerance)
+	if err != nil {
+		return false, false, paid
+	}
+	lower, err := due.Sub(tolerance)
+	if err != nil {
+		return false, false, paid
+	}
+	if c, err := total.Cmp(upper); err != nil || c > 0 {
+		return false, false, paid
+	}
+	if c, _ := total.Cmp(lower); c < 0 {
+		return true, true, total
+	}
+	return true, false, total
+}
+
+//部分付款按汇票顺序计入已付金额，前面的汇票付清后再计入后面的汇票
+func allocatePayment(drafts []trancheDraft, payment money.Money) error {
+	remaining := payment
+	for i := range drafts {
+		info := &drafts[i].Info
+		room, err := info.Sum.Sub(info.Paid)
+		if err != nil {
+			return err
+		}
+		pay := remaining
+		if c, _ := pay.Cmp(room); c > 0 && i < len(drafts) - 1 {
+			pay = room
+		}
+		info.Paid, err = info.Paid.Add(pay)
+		if err != nil {
+			return err
+		}
+		info.Outstanding, err = info.Sum.Sub(info.Paid)
+		if err != nil {
+			return err
+		}
+		remaining, err = remaining.Sub(pay)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//汇票进入下一个节点，已付金额清零，未付金额为汇票金额
+func resetPayment(draftInfo *draftInfoStruct) {
+	draftInfo.Paid = money.New(0, draftInfo.Sum.Currency)
+	draftInfo.Outstanding = draftInfo.Sum
+}
+
+//转账结果，transfer以json字符串返回
+type transferResult struct {
+	Matched bool 	//金额、出账账户、收款账户是否都匹配，不匹配时所属机构不变
+	Partial bool 	//是否部分付款，部分付款时所属机构不变
+	FailedChecks []string `json:",omitempty"`	//不匹配的检查项，取值为不匹配码
+	Reason string `json:",omitempty"`	//第一个不匹配项的原因
+	Overdue bool 	//是否逾期
+	DaysLate int `json:",omitempty"`	//逾期天数
+	Paid money.Money 	//涉及汇票本节点累计已付金额
+	Outstanding money.Money 	//涉及汇票本节点未付金额
+	PrevOwner string 	//转账前所属机构ID
+	NewOwner string 	//转账后所属机构ID
+	Status string 	//转账后的汇票状态
+	Drafts []string 	//涉及的汇票ID，第一个为调用时传入的汇票
+}
+
+//手工平账申请 参数有5个，一个是数字汇票ID，汇票变更后汇票所属机构ID，原因码，说明，操作人
+//只有不匹配状态的汇票可以手工平账；由汇票当前所属机构的开户银行申请，汇票当前所属机构在approveUpdate中复核后才生效
+func (t *SimpleChaincode) update(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	var request updateRequestStruct 	//手工平账申请
+
+	err := common.CheckArgs(args, 5)
+	if err != nil {
+		return nil, err
+	}
+
+	// Initialize the chaincode
+	request.DraftID = args[0]
+	request.NewOwner = args[1]
+	request.ReasonCode = args[2]
+	request.Note = strings.TrimSpace(args[3])
+	request.Requester = args[4]
+
+	if _, ok := reconcileReasons[request.ReasonCode]; !ok {
+		return nil, common.NewError(errInvalidReason, "The reason code " + request.ReasonCode + " is incorrect")
+	}
+	if request.Note == "" {
+		return nil, common.NewError(errInvalidReason, "The note of a manual reconciliation is empty")
+	}
+
+	_, drafts, err := loadTranche(stub, request.DraftID, request.Requester, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = checkTransition(drafts, "update")
+	if err != nil {
+		return nil, err
+	}
+	request.Owner = drafts[0].PrevOwner
+	//平账时按计划路径填写实际路径，变更后的所属机构必须是路由中的下一步，并持有计划路径中下一个节点的账户
+	err = checkNewOwner(stub, drafts[0], request.NewOwner, drafts[0].Info.PlanPath[drafts[0].Index + 1].Account)
+	if err != nil {
+		return nil, err
+	}
+
+	//同一张汇票同时只能有一个申请
+	requestKey, err := common.CreateCompositeKey(stub, keyUpdateRequest, request.DraftID)
+	if err != nil {
+		return nil, err
+	}
+	requestByte, err := stub.GetState(requestKey)
+	if err != nil {
+		return nil, errors.New("Failed to get state")
+	}
+	if requestByte != nil {
+		return nil, common.NewError(errInvalidState, "The draft " + request.DraftID + " already has a pending manual reconciliation")
+	}
+
+	request.TxID = stub.GetTxID()
+	request.Time, err = common.TxTime(stub)
+	if err != nil {
+		return nil, err
+	}
+	err = common.PutJSON(stub, requestKey, request)
+	if err != nil {
+		return nil, err
+	}
+
+	//发出手工平账申请事件
+	err = emitDraftEvent(stub, draftEvent{Type: eventDraftReconcileRequested, Drafts: trancheIDs(drafts), PrevOwner: request.Owner, NewOwner: request.NewOwner, Reason: request.ReasonCode})
+	if err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+//复核手工平账 参数有3个，一个是数字汇票ID，复核结果（approve或reject），操作人
+//复核人必须是汇票当前所属机构，不能是申请人；通过后把涉及的每张汇票该所属机构对应的实际路径按照计划路径填写上去，标记为手工平账，状态更改为Reconciled
+func (t *SimpleChaincode) approveUpdate(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	var request updateRequestStruct 	//手工平账申请
+	var truePathInfo InfoStruct 	//实际路径该节点的账户和实际转账时间信息结构体
+
+	err := common.CheckArgs(args, 3)
+	if err != nil {
+		return nil, err
+	}
+	draftID := args[0]
+	decision := args[1]
+	approver := args[2]
+
+	requestKey, err := common.CreateCompositeKey(stub, keyUpdateRequest, draftID)
+	if err != nil {
+		return nil, err
+	}
+	found, err := common.GetJSON(stub, requestKey, &request)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errors.New("The draft " + draftID + " has no pending manual reconciliation")
+	}
+
+	if approver == request.Requester || approver != request.Owner {
+		return nil, errors.New("The operator " + approver + " can not approve the manual reconciliation of draft " + draftID)
+	}
+	if decision != "approve" && decision != "reject" {
+		return nil, errors.New("The decision " + decision + " is incorrect. Expecting approve or reject")
+	}
+
+	//申请只能处理一次
+	err = stub.DelState(requestKey)
+	if err != nil {
+		return nil, err
+	}
+	if decision == "reject" {
+		return nil, nil
+	}
+
+	//申请后汇票可能已经变化，按申请人重新读出并校验
+	group, drafts, err := loadTranche(stub, draftID, request.Requester, nil)
+	if err != nil {
+		return nil, err
+	}
+	if drafts[0].PrevOwner != request.Owner {
+		return nil, common.NewError(errInvalidState, "The draft " + draftID + " is no longer owned by " + request.Owner)
+	}
+	err = checkTransition(drafts, "update")
+	if err != nil {
+		return nil, err
+	}
+	//申请后变更后的所属机构可能已经停用
+	err = checkNewOwner(stub, drafts[0], request.NewOwner, drafts[0].Info.PlanPath[drafts[0].Index + 1].Account)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range drafts {
+		//取出该汇票现阶段对应的出账账户和出账时间，标记为手工平账
+		truePathInfo = InfoStruct{}
+		truePathInfo.Account = drafts[i].Info.PlanPath[drafts[i].Index].Account
+		truePathInfo.Time = drafts[i].Info.PlanPath[drafts[i].Index].Time
+		truePathInfo.Source = pathSourceManual
+		truePathInfo.ReasonCode = request.ReasonCode
+		truePathInfo.Note = request.Note
+		truePathInfo.Requester = request.Requester
+		truePathInfo.Approver = approver
+
+		//将实际路径节点信息加到汇票信息中去
+		drafts[i].Info.TruePath = append(drafts[i].Info.TruePath, truePathInfo)
+		//变更汇票所属人
+		drafts[i].Info.Owner = request.NewOwner
+		drafts[i].Info.Status = statusReconciled
+		drafts[i].Info.MismatchCode = ""
+		drafts[i].Info.MismatchReason = ""
+		resetPayment(&drafts[i].Info)
+	}
+
+	//汇票信息变更完毕，将所有汇票信息重新存进区块链中
+	err = putTranche(stub, group, drafts, "update", approver, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	//发出平账事件
+	err = emitDraftEvent(stub, draftEvent{Type: eventDraftReconciled, Drafts: trancheIDs(drafts), PrevOwner: request.Owner, NewOwner: request.NewOwner, Reason: request.ReasonCode})
+	if err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+//冲正转账 参数有3个，一个是数字汇票ID，原因说明，操作人
+//冲正汇票最近一次转账或手工平账，需要转账前后的两个所属机构都同意：第一个机构调用时记录申请，另一个机构调用时执行冲正
+//执行时在实际路径追加一个冲正节点，所属机构恢复为转账前的机构，同一次转账涉及的同组汇票一起冲正
+func (t *SimpleChaincode) reverseTransfer(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	var request reverseRequestStruct 	//冲正申请
+	var drafts []trancheDraft 	//被冲正的交易涉及的所有汇票，第一张为draftID
+
+	err := common.CheckArgs(args, 3)
+	if err != nil {
+		return nil, err
+	}
+	draftID := args[0]
+	reason := strings.TrimSpace(args[1])
+	operator := args[2]
+
+	if reason == "" {
+		return nil, common.NewError(errInvalidReason, "The reason of a reversal is empty")
+	}
+
+	//最近一次所属机构变更必须是转账或手工平账
+	last, err := lastOwnerChange(stub, draftID)
+	if err != nil {
+		return nil, err
+	}
+	if last.Action != "transfer" && last.Action != "update" {
+		return nil, common.NewError(errInvalidState, "The draft " + draftID + " has no transfer to reverse")
+	}
+	if operator != last.PrevOwner && operator != last.NewOwner {
+		return nil, errors.New("The operator " + operator + " is neither the old owner nor the new owner of draft " + draftID)
+	}
+
+	group, err := getDraftGroup(stub, groupIDOf(draftID))
+	if err != nil {
+		return nil, err
+	}
+	if group == nil {
+		return nil, errors.New("The draft group of " + draftID + " is not found")
+	}
+
+	//同组中最近一次所属机构变更为同一个交易的汇票一起冲正，draftID排在第一张
+	for _, member := range group.Members {
+		memberLast := last
+		if member.DraftID != draftID {
+			memberLast, err = lastOwnerChange(stub, member.DraftID)
+			if err != nil {
+				return nil, err
+			}
+			if memberLast.TxID != last.TxID {
+				continue
+			}
+		}
+		draftInfo, err := getDraftInfo(stub, member.DraftID)
+		if err != nil {
+			return nil, err
+		}
+		if draftInfo.Owner != last.NewOwner {
+			return nil, common.NewError(errInvalidState, "The draft " + member.DraftID + " is no longer owned by " + last.NewOwner)
+		}
+		draft := trancheDraft{ID: member.DraftID, Info: draftInfo, PrevOwner: draftInfo.Owner, PrevStatus: draftInfo.Status}
+		if member.DraftID == draftID {
+			drafts = append([]trancheDraft{draft}, drafts...)
+		} else {
+			drafts = append(drafts, draft)
+		}
+	}
+	err = checkTransition(drafts, "reverse")
+	if err != nil {
+		return nil, err
+	}
+
+	//第一个机构同意时记录申请
+	requestKey, err := common.CreateCompositeKey(stub, keyReverseRequest, last.TxID)
+	if err != nil {
+		return nil, err
+	}
+	requestByte, err := stub.GetState(requestKey)
+	if err != nil {
+		return nil, errors.New("Failed to get state")
+	}
+	if requestByte == nil {
+		request = reverseRequestStruct{TxID: last.TxID, Drafts: trancheIDs(drafts), OldOwner: last.PrevOwner, NewOwner: last.NewOwner, Reason: reason, Requester: operator}
+		err = common.PutJSON(stub, requestKey, request)
+		if err != nil {
+			return nil, err
+		}
+
+		//发出冲正申请事件
+		err = emitDraftEvent(stub, draftEvent{Type: eventDraftReverseRequested, Drafts: request.Drafts, PrevOwner: request.NewOwner, NewOwner: request.OldOwner, Reason: reason})
+		if err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	//另一个机构同意时执行冲正
+	err = json.Unmarshal(requestByte, &request)
+	if err != nil {
+		return nil, err
+	}
+	if operator == request.Requester {
+		return nil, errors.New("The reversal of transaction " + last.TxID + " must be approved by the other owner")
+	}
+	err = stub.DelState(requestKey)
+	if err != nil {
+		return nil, err
+	}
+
+	date, err := txDate(stub)
+	if err != nil {
+		return nil, err
+	}
+	for i := range drafts {
+		info := &drafts[i].Info
+		reversed := InfoStruct{}
+		if len(info.TruePath) > 0 {
+			reversed = info.TruePath[len(info.TruePath) - 1]
+		}
+
+		//追加冲正节点，不删除被冲正的节点
+		info.TruePath = append(info.TruePath, InfoStruct{Account: reversed.Account, Time: date, Source: pathSourceReversal, Note: request.Reason, Requester: request.Requester, Approver: operator, ReversedTxID: last.TxID})
+		//恢复转账前的所属机构，转账前为发行机构时恢复为已发行
+		info.Owner = request.OldOwner
+		info.Status = statusInTransit
+		if info.Owner == info.Initiator {
+			info.Status = statusIssued
+		}
+		info.MismatchCode = ""
+		info.MismatchReason = ""
+		resetPayment(info)
+	}
+
+	err = putTranche(stub, group, drafts, "reverse", operator, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	//发出冲正事件
+	err = emitDraftEvent(stub, draftEvent{Type: eventDraftReversed, Drafts: trancheIDs(drafts), PrevOwner: request.NewOwner, NewOwner: request.OldOwner, Reason: request.Reason})
+	if err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+//冲正申请以被冲正的交易ID为组合键的属性存储，执行后删除
+const keyReverseRequest = "ReverseRequest"
+
+//冲正申请结构体
+type reverseRequestStruct struct {
+	TxID string 	//被冲正的交易ID
+	Drafts []string 	//涉及的汇票ID
+	OldOwner string 	//转账前所属机构ID，冲正后恢复
+	NewOwner string 	//转账后所属机构ID
+	Reason string 	//原因说明
+	Requester string 	//第一个同意的机构
+}
+
+//汇票最近一次所属机构变更（转账、手工平账或冲正）的历史
+func lastOwnerChange(stub shim.ChaincodeStubInterface, draftID string) (historyStruct, error) {
+	var last historyStruct
+
+	iter, err := stub.GetStateByPartialCompositeKey(keyDraftHistory, []string{draftID})
+	if err != nil {
+		return last, errors.New("Failed to get state")
+	}
+	defer iter.Close()
+
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return last, err
+		}
+		var history historyStruct
+		err = json.Unmarshal(kv.Value, &history)
+		if err != nil {
+			return last, err
+		}
+		if history.Action == "transfer" || history.Action == "update" || history.Action == "reverse" {
+			last = history
+		}
+	}
+	return last, nil
+}
+
+//手工平账申请以汇票ID为组合键的属性存储，复核后删除
+const keyUpdateRequest = "UpdateRequest"
+
+//手工平账申请结构体
+type updateRequestStruct struct {
+	DraftID string 	//汇票ID
+	Owner string 	//申请时汇票所属机构ID，即复核人
+	NewOwner string 	//平账后汇票所属机构ID
+	ReasonCode string 	//原因码
+	Note string 	//说明
+	Requester string 	//申请人，汇票所属机构的开户银行
+	TxID string 	//申请的交易ID
+	Time string 	//申请时间
+}
+
+//手工平账原因码
+var reconcileReasons = map[string]string{
+	"BANK_FLOW_MISSING": "银行流水缺失",
+	"BANK_FLOW_ERROR": "银行流水信息错误",
+	"ACCOUNT_CHANGED": "账户变更",
+	"SPLIT_PAYMENT": "拆分付款",
+	"OTHER": "其他，见说明",
+}
+
+//银行流水
+type bankEntryStruct struct {
+	BankTime string 	//转账时间
+	PayAccount string 	//付款账户
+	ReceiptAccount string 	//收款账户
+	Amount string 	//金额
+}
+
+//批量对账每一行的结果
+const (
+	batchMatched = "matched"	//找到汇票并转账成功，包括部分付款
+	batchMismatched = "mismatched"	//找到汇票但金额等不匹配，已记录不匹配状态
+	batchUnmatched = "unmatched"	//没有找到汇票
+	batchFailed = "error"	//找到汇票但转账出错，该行不做变更
+)
+
+//批量对账一行的结果
+type batchLineResult struct {
+	Line int 	//行号，json为数组下标加1，csv为文件中的行号
+	Result string 	//结果，见batchMatched等
+	DraftID string `json:",omitempty"`	//匹配到的汇票ID
+	Message string `json:",omitempty"`	//没有匹配或出错的原因
+	Transfer *transferResult `json:",omitempty"`	//转账结果
+}
+
+//批量对账报告
+type batchReport struct {
+	Matched int 	//转账成功的行数
+	Mismatched int 	//不匹配的行数
+	Unmatched int 	//没有找到汇票的行数
+	Failed int 	//出错的行数
+	Lines []batchLineResult 	//每一行的结果
+}
+
+//批量对账 参数有3个，一个是格式（json或csv），一个是银行流水，操作人
+//json为bankEntryStruct数组；csv与ICBC导出的流水一致，列依次为转账时间、付款账户、收款账户、金额，其余列忽略，第一行金额不是数字时视为表头
+//每一行按付款账户、收款账户找到当前节点与之相符的汇票，多张汇票相符时优先金额相符的，再按计划转账日期从早到晚
+//收款机构为收款账户在机构注册链码中所属的机构；逐行执行转账
+//fabric交易中读不到本交易的写入，所以汇票组在批次中只读取一次，各行在内存中修改，最后统一保存；
+//每张汇票在一个批次中只能变更一次，之后涉及同一张汇票的行作为出错处理，需要在下一个批次中重新提交
+func (t *SimpleChaincode) reconcileBatch(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	var report batchReport
+	var affected []string 	//所有行涉及的汇票ID
+	batch := &batchState{changed: make(map[string]int)}
+
+	err := common.CheckArgs(args, 3)
+	if err != nil {
+		return nil, err
+	}
+	operator := args[2]
+
+	entries, lines, err := parseBankEntries(args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	report.Lines = []batchLineResult{}
+	for i, entry := range entries {
+		line := batchLineResult{Line: lines[i]}
+		batch.line = line.Line
+
+		draftID, err := findDraftForEntry(stub, entry, operator, batch)
+		if err != nil {
+			return nil, err
+		}
+		newOwner, ownerErr := common.GetOrgInfoByAccount(stub, entry.ReceiptAccount)
+		if draftID == "" || ownerErr != nil {
+			line.Result = batchUnmatched
+			line.Message = "No draft is waiting for a transfer from " + entry.PayAccount + " to " + entry.ReceiptAccount
+			if draftID != "" {
+				line.Message = "The receiptAccount " + entry.ReceiptAccount + " does not belong to any organization"
+			}
+			report.Unmatched++
+			report.Lines = append(report.Lines, line)
+			continue
+		}
+
+		line.DraftID = draftID
+		result, _, err := applyTransfer(stub, draftID, newOwner.OrgID, entry.Amount, entry.PayAccount, entry.ReceiptAccount, entry.BankTime, operator, batch)
+		if err != nil {
+			line.Result = batchFailed
+			line.Message = err.Error()
+			report.Failed++
+			report.Lines = append(report.Lines, line)
+			continue
+		}
+		line.Transfer = &result
+		if result.Matched {
+			line.Result = batchMatched
+			report.Matched++
+		} else {
+			line.Result = batchMismatched
+			report.Mismatched++
+		}
+		report.Lines = append(report.Lines, line)
+		affected = append(affected, result.Drafts...)
+	}
+
+	//保存各行修改过的汇票组
+	err = batch.putGroups(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	//fabric每个交易只能发出一个事件，所有行涉及的汇票放在一个事件中
+	if len(affected) > 0 {
+		err = emitDraftEvent(stub, draftEvent{Type: eventDraftBatchReconciled, Drafts: affected})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(report)
+}
+
+//解析银行流水，返回流水和每条流水的行号
+func parseBankEntries(format string, data string) ([]bankEntryStruct, []int, error) {
+	var entries []bankEntryStruct
+	var lines []int
+
+	switch format {
+	case "json":
+		err := json.Unmarshal([]byte(data), &entries)
+		if err != nil {
+			return nil, nil, errors.New("The bank entries are not valid json: " + err.Error())
+		}
+		for i := range entries {
+			lines = append(lines, i + 1)
+		}
+	case "csv":
+		reader := csv.NewReader(strings.NewReader(data))
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		records, err := reader.ReadAll()
+		if err != nil {
+			return nil, nil, errors.New("The bank entries are not valid csv: " + err.Error())
+		}
+		for i, record := range records {
+			if len(record) < 4 {
+				return nil, nil, errors.New("The csv line " + strconv.Itoa(i + 1) + " needs at least 4 columns")
+			}
+			if _, err := money.Parse(record[3]); err != nil && i == 0 {
+				continue
+			}
+			entries = append(entries, bankEntryStruct{BankTime: record[0], PayAccount: record[1], ReceiptAccount: record[2], Amount: record[3]})
+			lines = append(lines, i + 1)
+		}
+	default:
+		return nil, nil, errors.New("The format " + format + " is incorrect. Expecting json or csv")
+	}
+	return entries, lines, nil
+}
+
+//批量对账的批次：fabric交易中读不到本交易的写入，所以汇票组只从账本读取一次，各行在内存中修改，最后统一保存
+//变更过的汇票在账本中读到的仍是变更前的信息，同一张汇票在一个批次中只能变更一次
+type batchState struct {
+	groups []*draftGroupStruct 	//本批次读取的汇票组，按读取顺序
+	changed map[string]int 	//本批次已经变更的汇票ID → 行号
+	line int 	//当前处理的行号
+}
+
+//读取汇票组，批次中已经读取过时返回批次中的汇票组；batch为nil时直接从账本读取
+func (b *batchState) getGroup(stub shim.ChaincodeStubInterface, groupID string) (*draftGroupStruct, error) {
+	if b == nil {
+		return getDraftGroup(stub, groupID)
+	}
+	for _, group := range b.groups {
+		if group.GroupID == groupID {
+			return group, nil
+		}
+	}
+	group, err := getDraftGroup(stub, groupID)
+	if err != nil || group == nil {
+		return nil, err
+	}
+	b.groups = append(b.groups, group)
+	return group, nil
+}
+
+//汇票在本批次中已经变更过时返回错误
+func (b *batchState) checkUnchanged(draftID string) error {
+	if b == nil {
+		return nil
+	}
+	if changedLine, ok := b.changed[draftID]; ok {
+		return errors.New("The draft " + draftID + " is already changed by line " + strconv.Itoa(changedLine) + " in this batch, submit this line in a later batch")
+	}
+	return nil
+}
+
+//记录当前行变更的汇票
+func (b *batchState) markChanged(drafts []trancheDraft) {
+	for _, draft := range drafts {
+		b.changed[draft.ID] = b.line
+	}
+}
+
+//保存有汇票变更过的汇票组，每个汇票组只写入一次
+func (b *batchState) putGroups(stub shim.ChaincodeStubInterface) error {
+	for _, group := range b.groups {
+		for _, member := range group.Members {
+			if _, ok := b.changed[member.DraftID]; ok {
+				err := putDraftGroup(stub, group)
+				if err != nil {
+					return err
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
+
+//对账候选：同一汇票组中付款机构持有、当前节点与流水相符的汇票，转账时一起转出
+type batchCandidate struct {
+	DraftID string 	//汇票ID，取第一张
+	PlanTime string 	//当前节点的计划转账日期
+	Due money.Money 	//涉及汇票的总金额
+	Paid money.Money 	//涉及汇票本节点已付金额
+	Tolerance money.Money 	//手续费容差
+	Rank int 	//金额匹配程度：0付清，1部分付款，2不匹配
+}
+
+//找到当前节点的付款账户、收款账户与流水相符的汇票，没有时返回空字符串
+//付款账户所属的机构即汇票当前所属机构，通过所属机构索引只读取该机构持有的汇票；该机构的开户银行必须是操作人
+//同一汇票组中同一次转账涉及的多张汇票只取一张，金额按这些汇票的加和比较
+func findDraftForEntry(stub shim.ChaincodeStubInterface, entry bankEntryStruct, operator string, batch *batchState) (string, error) {
+	var candidates []*batchCandidate
+
+	payOrg, err := common.GetOrgInfoByAccount(stub, entry.PayAccount)
+	if err != nil || payOrg.Bank != operator {
+		return "", nil
+	}
+	draftIDs, err := draftIDsByIndex(stub, keyDraftOwner, payOrg.OrgID)
+	if err != nil {
+		return "", err
+	}
+
+	for _, draftID := range draftIDs {
+		draftInfo, err := getDraftInfo(stub, draftID)
+		if err != nil {
+			return "", err
+		}
+		if checkTransition([]trancheDraft{{ID: draftID, Info: draftInfo}}, "transfer") != nil {
+			continue
+		}
+		//发行机构的角色从汇票组中读取，不再查询机构注册链码
+		group, err := batch.getGroup(stub, draftInfo.GroupID)
+		if err != nil {
+			return "", err
+		}
+		if group == nil {
+			continue
+		}
+		initiatorRole := ""
+		for _, member := range group.Members {
+			if member.DraftID == draftID {
+				initiatorRole = member.InitiatorRole
+			}
+		}
+		route, err := getRouteTemplate(stub, draftInfo.Route, initiatorRole)
+		if err != nil {
+			continue
+		}
+		index, ok := route.stepIndex(payOrg.Role)
+		if !ok || len(draftInfo.PlanPath) < index + 2 {
+			continue
+		}
+		if !strings.EqualFold(draftInfo.PlanPath[index].Account, entry.PayAccount) || !strings.EqualFold(draftInfo.PlanPath[index + 1].Account, entry.ReceiptAccount) {
+			continue
+		}
+
+		//同一汇票组的汇票在同一次转账中，金额累加到第一张
+		var candidate *batchCandidate
+		for _, c := range candidates {
+			if groupIDOf(c.DraftID) == draftInfo.GroupID {
+				candidate = c
+			}
+		}
+		if candidate == nil {
+			candidate = &batchCandidate{DraftID: draftID, PlanTime: draftInfo.PlanPath[index].Time, Due: money.New(0, draftInfo.Sum.Currency), Paid: money.New(0, draftInfo.Sum.Currency), Tolerance: route.FeeTolerance}
+			candidates = append(candidates, candidate)
+		}
+		candidate.Due, _ = candidate.Due.Add(draftInfo.Sum)
+		candidate.Paid, _ = candidate.Paid.Add(draftInfo.Paid)
+	}
+
+	if len(candidates) == 0 {
+		return "", nil
+	}
+	amount, amountErr := money.Parse(entry.Amount)
+	for _, candidate := range candidates {
+		candidate.Rank = 2
+		if amountErr == nil {
+			matched, partial, _ := matchAmount(candidate.Due, candidate.Paid, amount, candidate.Tolerance)
+			if matched && !partial {
+				candidate.Rank = 0
+			} else if matched {
+				candidate.Rank = 1
+			}
+		}
+	}
+	best := candidates[0]
+	for _, candidate := range candidates[1:] {
+		if candidate.Rank < best.Rank || (candidate.Rank == best.Rank && candidate.PlanTime < best.PlanTime) {
+			best = candidate
+		}
+	}
+	return best.DraftID, nil
+}
+
+//结清 参数有2个，一个是数字汇票ID，操作人
+//汇票到达最终到账机构后，由最终到账机构的开户银行确认结清
+func (t *SimpleChaincode) settle(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	err := common.CheckArgs(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	draftID := args[0]
+	operator := args[1]
+
+	draftInfo, err := getDraftInfo(stub, draftID)
+	if err != nil {
+		return nil, err
+	}
+	if draftInfo.Owner != draftInfo.Target {
+		return nil, common.NewError(errInvalidState, "The draft " + draftID + " has not reached the target " + draftInfo.Target)
+	}
+	err = checkOwnerBank(stub, draftInfo.Owner, operator)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, changeDraftStatus(stub, draftID, draftInfo, "settle", statusSettled, eventDraftSettled, operator)
+}
+
+//作废 参数有2个，一个是数字汇票ID，操作人
+//只有发行机构可以作废，并且只能在第一次转账之前（已发行状态）作废
+func (t *SimpleChaincode) cancel(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	err := common.CheckArgs(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	draftID := args[0]
+	operator := args[1]
+
+	draftInfo, err := getDraftInfo(stub, draftID)
+	if err != nil {
+		return nil, err
+	}
+	if operator != draftInfo.Initiator {
+		return nil, errors.New("The operator " + operator + " is not the initiator " + draftInfo.Initiator)
+	}
+	//冲正后状态回到已发行，但实际路径中还有转账和冲正节点，仍然视为已转账
+	if len(draftInfo.TruePath) > 0 {
+		return nil, common.NewError(errInvalidState, "The draft " + draftID + " has been transferred")
+	}
+
+	err = changeDraftStatus(stub, draftID, draftInfo, "cancel", statusCancelled, eventDraftCancelled, operator)
+	if err != nil {
+		return nil, err
+	}
+
+	//作废的汇票不再与组内其他汇票一起转账
+	group, err := getDraftGroup(stub, groupIDOf(draftID))
+	if err != nil || group == nil {
+		return nil, err
+	}
+	err = closeGroupMember(group, draftID)
+	if err != nil {
+		return nil, err
+	}
+	return nil, putDraftGroup(stub, group)
+}
+
+//过期 参数有1个，操作人
+//操作人发行的汇票中，计划路径最后一个节点的日期已过（按交易日期判断）仍未结清的标记为过期，有关联项目的同时标记项目资金进度
+//项目链码只接受汇票发行机构标记过期，所以每个发行机构只处理自己发行的汇票；结果只取决于交易时间，可以由各发行机构的定时任务调用
+//返回本次过期的汇票（json数组）；标记资金进度失败不影响汇票过期，失败原因记在该汇票的结果中
+func (t *SimpleChaincode) expireOverdue(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	var expired []string 	//本次过期的汇票ID
+	var results []expireResult 	//每张过期汇票的结果
+	var groups []*draftGroupStruct 	//本次过期的汇票所属的汇票组，同一交易中读不到本交易写入的汇票组，所以在内存中修改，最后统一保存
+
+	err := common.CheckArgs(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	operator := args[0]
+
+	today, err := txDate(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, status := range draftTransitions["expire"] {
+		draftIDs, err := draftIDsByIndex(stub, keyDraftStatus, status)
+		if err != nil {
+			return nil, err
+		}
+		for _, draftID := range draftIDs {
+			draftInfo, err := getDraftInfo(stub, draftID)
+			if err != nil {
+				return nil, err
+			}
+			if draftInfo.Initiator != operator {
+				continue
+			}
+			if len(draftInfo.PlanPath) == 0 || draftInfo.PlanPath[len(draftInfo.PlanPath) - 1].Time >= today {
+				continue
+			}
+
+			prevInfo := draftInfo
+			draftInfo.Status = statusExpired
+			err = putDraftInfo(stub, draftID, &prevInfo, draftInfo)
+			if err != nil {
+				return nil, err
+			}
+			err = recordHistory(stub, draftID, "expire", operator, draftInfo.Owner, draftInfo)
+			if err != nil {
+				return nil, err
+			}
+
+			//一张汇票标记失败（例如项目已被删除）不能让其他汇票一直无法过期
+			result := expireResult{DraftID: draftID, ProjectID: draftInfo.ProjectID}
+			if draftInfo.ProjectID != "" {
+				err = expireFundProgress(stub, draftInfo.ProjectID, draftID, operator)
+				if err != nil {
+					result.FlagError = err.Error()
+				}
+			}
+			expired = append(expired, draftID)
+			results = append(results, result)
+
+			//过期的汇票不再与组内其他汇票一起转账
+			var group *draftGroupStruct
+			for _, g := range groups {
+				if g.GroupID == groupIDOf(draftID) {
+					group = g
+				}
+			}
+			if group == nil {
+				group, err = getDraftGroup(stub, groupIDOf(draftID))
+				if err != nil {
+					return nil, err
+				}
+				if group == nil {
+					continue
+				}
+				groups = append(groups, group)
+			}
+			err = closeGroupMember(group, draftID)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, group := range groups {
+		err = putDraftGroup(stub, group)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	//fabric每个交易只能发出一个事件，本次过期的汇票放在一个事件中
+	if len(expired) > 0 {
+		err = emitDraftEvent(stub, draftEvent{Type: eventDraftExpired, Drafts: expired})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(results)
+}
+
+//过期结果中的一张汇票，expireOverdue以json数组返回
+type expireResult struct {
+	DraftID string 	//过期的汇票ID
+	ProjectID string `json:",omitempty"`	//关联的项目ID
+	FlagError string `json:",omitempty"`	//标记项目资金进度失败的原因，发行机构可以直接调用项目链码的expireFundProgress补标
+}
+
+//读取项目链码名称，没有设置时返回错误
+func getProjectChaincode(stub shim.ChaincodeStubInterface) (string, error) {
+	projectChaincode, err := stub.GetState("ProjectChaincode")
+	if err != nil {
+		return "", errors.New("Failed to get state")
+	}
+	if projectChaincode == nil {
+		return "", errors.New("The project chaincode is not set")
+	}
+	return string(projectChaincode), nil
+}
+
+//调用项目链码标记资金进度中的汇票已过期
+func expireFundProgress(stub shim.ChaincodeStubInterface, projectID string, draftID string, operator string) error {
+	projectChaincode, err := getProjectChaincode(stub)
+	if err != nil {
+		return err
+	}
+
+	_, err = common.CallChaincode(stub, projectChaincode, "expireFundProgress", projectID, draftID, operator)
+	if err != nil {
+		return errors.New("Failed to flag the fund progress of project " + projectID + ": " + err.Error())
+	}
+	return nil
+}
+
+//校验汇票关联的项目：项目链码已设置，并且项目已在项目链码中创建，否则汇票过期时无法标记资金进度
+func checkProject(stub shim.ChaincodeStubInterface, projectID string) error {
+	projectChaincode, err := getProjectChaincode(stub)
+	if err != nil {
+		return common.NewError(errInvalidDraftInfo, "The project " + projectID + " can not be checked: " + err.Error())
+	}
+
+	_, err = common.CallChaincode(stub, projectChaincode, "getProject", projectID)
+	if err != nil {
+		return common.NewError(errInvalidDraftInfo, "The project " + projectID + " is not found: " + err.Error())
+	}
+	return nil
+}
+
+//设置项目链码 传入参数有2个：项目链码名称，操作人编号；只能由管理员调用
+func (t *SimpleChaincode) setProjectChaincode(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	err := common.CheckArgs(args, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	//项目链码只能设置一次，设置之后过期的汇票都通过它标记资金进度
+	projectChaincode, err := stub.GetState("ProjectChaincode")
+	if err != nil {
+		return nil, errors.New("Failed to get state")
+	}
+	if projectChaincode != nil {
+		return nil, errors.New("The project chaincode is already set")
+	}
+
+	// Write the state to the ledger
+	err = stub.PutState("ProjectChaincode", []byte(args[0]))
+	if err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+//校验状态转换后变更一张汇票的状态，所属机构不变，追加历史并发出事件
+func changeDraftStatus(stub shim.ChaincodeStubInterface, draftID string, draftInfo draftInfoStruct, action string, status string, eventType string, operator string) error {
+	err := checkTransition([]trancheDraft{{ID: draftID, Info: draftInfo}}, action)
+	if err != nil {
+		return err
+	}
+
+	prevInfo := draftInfo
+	draftInfo.Status = status
+	draftInfo.MismatchCode = ""
+	draftInfo.MismatchReason = ""
+	err = putDraftInfo(stub, draftID, &prevInfo, draftInfo)
+	if err != nil {
+		return err
+	}
+	err = recordHistory(stub, draftID, action, operator, draftInfo.Owner, draftInfo)
+	if err != nil {
+		return err
+	}
+
+	return emitDraftEvent(stub, draftEvent{Type: eventType, Drafts: []string{draftID}, PrevOwner: draftInfo.Owner, NewOwner: draftInfo.Owner})
+}
+
+//一次转账或平账涉及的一张汇票
+type trancheDraft struct {
+	ID string 	//汇票ID
+	Info draftInfoStruct 	//汇票信息，校验通过后在这里修改，最后统一写入
+	PrevOwner string 	//变更前所属机构ID
+	PrevStatus string 	//变更前状态，用于维护二级索引
+	Index int 	//所属机构在该汇票计划路径中的位置
+	Route routeTemplateStruct 	//该汇票的路由模板
+}
+
+//读取汇票信息
+func getDraftInfo(stub shim.ChaincodeStubInterface, draftID string) (draftInfoStruct, error) {
+	var draftInfo draftInfoStruct
+
+	found, err := common.GetJSON(stub, draftID, &draftInfo)
+	if err != nil {
+		return draftInfo, err
+	}
+	if !found {
+		return draftInfo, errors.New("Entity not found")
+	}
+	normalizeStatus(&draftInfo)
+	//原来的汇票没有记录已付、未付金额
+	if draftInfo.Outstanding.Currency == "" && draftInfo.Paid.Minor == 0 {
+		resetPayment(&draftInfo)
+	}
+	return draftInfo, nil
+}
+
+//保存汇票信息，同时维护二级索引；prevInfo为变更前的汇票信息，新发行的汇票为nil
+func putDraftInfo(stub shim.ChaincodeStubInterface, draftID string, prevInfo *draftInfoStruct, draftInfo draftInfoStruct) error {
+	err := common.PutJSON(stub, draftID, draftInfo)
+	if err != nil {
+		return err
+	}
+
+	for _, index := range draftIndexes {
+		value := index.Value(draftInfo)
+		if prevInfo != nil {
+			prevValue := index.Value(*prevInfo)
+			if prevValue == value {
+				continue
+			}
+			if prevValue != "" {
+				prevKey, err := common.CreateCompositeKey(stub, index.Key, prevValue, draftID)
+				if err != nil {
+					return err
+				}
+				err = stub.DelState(prevKey)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		key, err := common.CreateCompositeKey(stub, index.Key, value, draftID)
+		if err != nil {
+			return err
+		}
+		err = stub.PutState(key, []byte{0x00})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//重建二级索引 参数有1个，操作人
+//索引在汇票写入时维护，原来没有索引的汇票通过本函数补建；汇票ID为九位数字，按键的范围遍历所有汇票
+func (t *SimpleChaincode) reindexDrafts(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	var count int 	//重建索引的汇票数
+
+	err := common.CheckArgs(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := stub.GetStateByRange("000000000", "999999999" + string(utf8.MaxRune))
+	if err != nil {
+		return nil, errors.New("Failed to get state")
+	}
+	var draftIDs []string
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			iter.Close()
+			return nil, err
+		}
+		if isDraftID(kv.Key) {
+			draftIDs = append(draftIDs, kv.Key)
+		}
+	}
+	iter.Close()
+
+	for _, draftID := range draftIDs {
+		draftInfo, err := getDraftInfo(stub, draftID)
+		if err != nil {
+			return nil, err
+		}
+		//索引键已存在时重复写入不影响结果
+		err = putDraftInfo(stub, draftID, nil, draftInfo)
+		if err != nil {
+			return nil, err
+		}
+		count++
+	}
+	return []byte(strconv.Itoa(count)), nil
+}
+
+//根据汇票当前所属机构从汇票组中确定本次转账或平账涉及的汇票，全部读出并校验，任何一张不满足条件都返回错误，不写入任何汇票
+//路由中包含当前所属机构角色的组内汇票都涉及，例如默认模板下县、省、ICBC只涉及这一张，有限合伙涉及省、ICBC发行的汇票，SPV涉及组内所有汇票
+//组内其他已作废或过期的汇票不涉及，金额也不计入
+//每张汇票的当前节点为所属机构角色在该汇票路由中的位置
+//校验：汇票都存在，所属机构相同，计划路径中当前节点的出账账户、收款账户、时间一致；操作人必须是所属机构的开户银行
+//batch不为nil时汇票组从批次中读取，涉及的汇票在本批次中已经变更过时返回错误
+func loadTranche(stub shim.ChaincodeStubInterface, draftID string, operator string, batch *batchState) (*draftGroupStruct, []trancheDraft, error) {
+	var drafts []trancheDraft
+
+	draftInfo, err := getDraftInfo(stub, draftID)
+	if err != nil {
+		return nil, nil, err
+	}
+	owner := draftInfo.Owner
+
+	//只有汇票当前所属机构的开户银行可以操作
+	err = checkOwnerBank(stub, owner, operator)
+	if err != nil {
+		return nil, nil, err
+	}
+	//通过机构注册链码查询汇票当前所属机构的角色
+	ownerRole, err := common.GetOrgRole(stub, owner)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	group, err := batch.getGroup(stub, groupIDOf(draftID))
+	if err != nil {
+		return nil, nil, err
+	}
+	if group == nil {
+		return nil, nil, errors.New("The draft group of " + draftID + " is not found")
+	}
+
+	//draftID排在第一张
+	for _, member := range group.Members {
+		route, err := getRouteTemplate(stub, member.Route, member.InitiatorRole)
+		if err != nil {
+			return nil, nil, err
+		}
+		index, ok := route.stepIndex(ownerRole)
+		if !ok {
+			continue
+		}
+
+		if member.DraftID == draftID {
+			drafts = append([]trancheDraft{{ID: draftID, Info: draftInfo, PrevOwner: owner, PrevStatus: draftInfo.Status, Index: index, Route: route}}, drafts...)
+			continue
+		}
+		//已作废或过期的汇票不再转账，不涉及；原来作废的汇票在汇票组中没有标记，按汇票状态判断
+		if member.Closed {
+			continue
+		}
+		tmpDraftInfo, err := getDraftInfo(stub, member.DraftID)
+		if err != nil {
+			return nil, nil, errors.New("Failed to get draft " + member.DraftID + " of the same group: " + err.Error())
+		}
+		if tmpDraftInfo.Status == statusCancelled || tmpDraftInfo.Status == statusExpired {
+			continue
+		}
+		drafts = append(drafts, trancheDraft{ID: member.DraftID, Info: tmpDraftInfo, PrevOwner: tmpDraftInfo.Owner, PrevStatus: tmpDraftInfo.Status, Index: index, Route: route})
+	}
+	if len(drafts) == 0 || drafts[0].ID != draftID {
+		return nil, nil, errors.New("The draft information is incorrect!")
+	}
+
+	for i := range drafts {
+		draft := &drafts[i]
+		//批次中变更过的汇票读到的是变更前的信息，先于其他校验报告
+		err = batch.checkUnchanged(draft.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if draft.Info.Owner != owner {
+			return nil, nil, errors.New("The draft " + draft.ID + " is owned by " + draft.Info.Owner + ", not " + owner)
+		}
+		//路由的最后一个节点为最终到账机构，不能再转出
+		if len(draft.Info.PlanPath) < draft.Index + 2 {
+			return nil, nil, common.NewError(errInvalidPlanPath, "The planPath of draft " + draft.ID + " is too short for owner " + owner)
+		}
+
+		//和draftID计划路径的当前节点比较
+		step := draft.Info.PlanPath[draft.Index]
+		nextStep := draft.Info.PlanPath[draft.Index + 1]
+		firstStep := drafts[0].Info.PlanPath[drafts[0].Index]
+		firstNextStep := drafts[0].Info.PlanPath[drafts[0].Index + 1]
+		if !strings.EqualFold(step.Account, firstStep.Account) || !strings.EqualFold(nextStep.Account, firstNextStep.Account) || step.Time != firstStep.Time {
+			return nil, nil, common.NewError(errInvalidPlanPath, "The planPath step of draft " + draft.ID + " does not match draft " + draftID)
+		}
+	}
+	return group, drafts, nil
+}
+
+//将涉及的所有汇票写入账本，每张汇票追加一条历史，同时更新汇票组中这些汇票的所属机构
+//batch不为nil时汇票组由批次最后统一保存，这里只记录变更过的汇票
+func putTranche(stub shim.ChaincodeStubInterface, group *draftGroupStruct, drafts []trancheDraft, action string, operator string, batch *batchState) error {
+	for _, draft := range drafts {
+		prevInfo := draftInfoStruct{Initiator: draft.Info.Initiator, Target: draft.Info.Target, Owner: draft.PrevOwner, Status: draft.PrevStatus}
+		err := putDraftInfo(stub, draft.ID, &prevInfo, draft.Info)
+		if err != nil {
+			return err
+		}
+		err = recordHistory(stub, draft.ID, action, operator, draft.PrevOwner, draft.Info)
+		if err != nil {
+			return err
+		}
+
+		for i := range group.Members {
+			if group.Members[i].DraftID == draft.ID {
+				group.Members[i].Owner = draft.Info.Owner
+			}
+		}
+	}
+	if batch != nil {
+		batch.markChanged(drafts)
+		return nil
+	}
+	return putDraftGroup(stub, group)
+}
+
+//汇票状态
+const (
+	statusIssued = "Issued"	//已发行，还没有转账
+	statusInTransit = "InTransit"	//转账成功，在途
+	statusMismatch = "Mismatch"	//转账信息与汇票不匹配
+	statusPartiallyPaid = "PartiallyPaid"	//当前节点部分付款，还没有付清
+	statusReconciled = "Reconciled"	//手工平账
+	statusSettled = "Settled"	//到达最终到账机构，已结清
+	statusCancelled = "Cancelled"	//已作废
+	statusExpired = "Expired"	//已过期
+)
+
+//不匹配码
+const (
+	mismatchAmount = "AMOUNT"	//金额不符
+	mismatchPayAccount = "PAY_ACCOUNT"	//出账账户不符
+	mismatchReceiptAccount = "RECEIPT_ACCOUNT"	//收款账户不符
+)
+
+//不匹配码对应的原因，与原来记录在状态中的文字一致
+var mismatchReasons = map[string]string{
+	mismatchAmount: "The amount of money is incorrect!",
+	mismatchPayAccount: "The payAccount is incorrect!",
+	mismatchReceiptAccount: "The receiptAccount is incorrect!",
+}
+
+//状态转换表：操作 → 允许执行该操作的状态，Settled、Cancelled、Expired为终态
+var draftTransitions = map[string][]string{
+	"transfer": {statusIssued, statusInTransit, statusMismatch, statusPartiallyPaid, statusReconciled},
+	"update": {statusMismatch},
+	"settle": {statusInTransit, statusReconciled},
+	"reverse": {statusInTransit, statusReconciled},
+	"cancel": {statusIssued},
+	"expire": {statusIssued, statusInTransit, statusMismatch, statusPartiallyPaid, statusReconciled},
+}
+
+//二级索引以索引值、汇票ID为组合键的属性存储，值为空
+const (
+	keyDraftStatus = "DraftStatus"	//状态索引
+	keyDraftOwner = "DraftOwner"	//当前所属机构索引
+	keyDraftInitiator = "DraftInitiator"	//发行机构索引
+	keyDraftTarget = "DraftTarget"	//最终到账机构索引
+)
+
+//二级索引：组合键类型 → 索引值
+var draftIndexes = []struct {
+	Key string
+	Value func(draftInfoStruct) string
+}{
+	{keyDraftStatus, func(d draftInfoStruct) string { return d.Status }},
+	{keyDraftOwner, func(d draftInfoStruct) string { return d.Owner }},
+	{keyDraftInitiator, func(d draftInfoStruct) string { return d.Initiator }},
+	{keyDraftTarget, func(d draftInfoStruct) string { return d.Target }},
+}
+
+//通过二级索引查询索引值为value的所有汇票ID，例如某个状态或某个所属机构的汇票
+func draftIDsByIndex(stub shim.ChaincodeStubInterface, indexKey string, value string) ([]string, error) {
+	var draftIDs []string
+
+	iter, err := stub.GetStateByPartialCompositeKey(indexKey, []string{value})
+	if err != nil {
+		return nil, errors.New("Failed to get state")
+	}
+	defer iter.Close()
+
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, attributes, err := stub.SplitCompositeKey(kv.Key)
+		if err != nil {
+			return nil, err
+		}
+		draftIDs = append(draftIDs, attributes[1])
+	}
+	return draftIDs, nil
+}
+
+//兼容原来的状态：空字符串为已发行或在途，其他文字为不匹配原因
+func normalizeStatus(draftInfo *draftInfoStruct) {
+	switch draftInfo.Status {
+	case statusIssued, statusInTransit, statusMismatch, statusPartiallyPaid, statusReconciled, statusSettled, statusCancelled, statusExpired:
+		return
+	case "":
+		if len(draftInfo.TruePath) == 0 {
+			draftInfo.Status = statusIssued
+		} else {
+			draftInfo.Status = statusInTransit
+		}
+	default:
+		draftInfo.MismatchReason = draftInfo.Status
+		for code, reason := range mismatchReasons {
+			if reason == draftInfo.Status {
+				draftInfo.MismatchCode = code
+			}
+		}
+		draftInfo.Status = statusMismatch
+	}
+}
+
+//校验涉及的所有汇票的状态是否允许该操作
+func checkTransition(drafts []trancheDraft, action string) error {
+	for _, draft := range drafts {
+		allowed := false
+		for _, status := range draftTransitions[action] {
+			if draft.Info.Status == status {
+				allowed = true
+			}
+		}
+		if !allowed {
+			return common.NewError(errInvalidState, "The draft " + draft.ID + " in state " + draft.Info.Status + " can not " + action)
+		}
+	}
+	return nil
+}
+
+//汇票组以组ID为组合键的属性存储
+const keyDraftGroup = "DraftGroup"
+
+//汇票组成员
+type groupMemberStruct struct {
+	DraftID string 	//汇票ID
+	Initiator string 	//发行机构ID
+	InitiatorRole string 	//发行机构角色
+	Route string 	//路由模板名称，决定转账时是否与其他汇票一起操作
+	Sum money.Money 	//汇票金额
+	Owner string 	//当前所属机构ID
+	Closed bool `json:",omitempty"`	//已作废或过期，不再与组内其他汇票一起转账
+}
+
+//汇票组，同一笔大汇票拆成的县、省、ICBC发行的汇票
+type draftGroupStruct struct {
+	GroupID string 	//汇票组ID，汇票ID的前八位
+	Members []groupMemberStruct 	//组内汇票，按发行顺序
+	Total money.Money 	//组内汇票总金额，不包括已作废或过期的汇票
+	Stage string 	//当前阶段：组内未作废、过期的汇票都属于同一机构时为该机构ID，否则为split
+}
+
+//汇票组内汇票属于不同机构时的阶段
+const groupStageSplit = "split"
+
+//汇票组ID为汇票ID的前八位
+func groupIDOf(draftID string) string {
+	return draftID[0 : len(draftID)-1]
+}
+
+//读取汇票组，不存在时返回nil
+func getDraftGroup(stub shim.ChaincodeStubInterface, groupID string) (*draftGroupStruct, error) {
+	var group draftGroupStruct
+
+	key, err := common.CreateCompositeKey(stub, keyDraftGroup, groupID)
+	if err != nil {
+		return nil, err
+	}
+	found, err := common.GetJSON(stub, key, &group)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &group, nil
+}
+
+//根据成员的所属机构计算当前阶段后保存汇票组
+func putDraftGroup(stub shim.ChaincodeStubInterface, group *draftGroupStruct) error {
+	group.Stage = ""
+	for _, member := range group.Members {
+		if member.Closed {
+			continue
+		}
+		if group.Stage == "" {
+			group.Stage = member.Owner
+		} else if group.Stage != member.Owner {
+			group.Stage = groupStageSplit
+			break
+		}
+	}
+
+	key, err := common.CreateCompositeKey(stub, keyDraftGroup, group.GroupID)
+	if err != nil {
+		return err
+	}
+	return common.PutJSON(stub, key, group)
+}
+
+//新发行的汇票加入汇票组，汇票组不存在时创建，总金额加上该汇票的金额
+func addGroupMember(stub shim.ChaincodeStubInterface, draftID string, draftInfo draftInfoStruct) error {
+	initiatorRole, err := common.GetOrgRole(stub, draftInfo.Initiator)
+	if err != nil {
+		return err
+	}
+
+	group, err := getDraftGroup(stub, draftInfo.GroupID)
+	if err != nil {
+		return err
+	}
+	if group == nil {
+		group = &draftGroupStruct{GroupID: draftInfo.GroupID, Total: money.New(0, draftInfo.Sum.Currency)}
+	}
+
+	for _, member := range group.Members {
+		if member.DraftID == draftID {
+			return common.NewError(errDraftExists, "The draft " + draftID + " is already in group " + group.GroupID)
+		}
+	}
+	group.Total, err = group.Total.Add(draftInfo.Sum)
+	if err != nil {
+		return common.NewError(errInvalidSum, "The sum of draft " + draftID + " can not be added to group " + group.GroupID + ": " + err.Error())
+	}
+	group.Members = append(group.Members, groupMemberStruct{DraftID: draftID, Initiator: draftInfo.Initiator, InitiatorRole: initiatorRole, Route: draftInfo.Route, Sum: draftInfo.Sum, Owner: draftInfo.Owner})
+
+	return putDraftGroup(stub, group)
+}
+
+//汇票作废或过期后标记为不再与组内其他汇票一起转账，总金额减去该汇票的金额，由调用方保存汇票组
+func closeGroupMember(group *draftGroupStruct, draftID string) error {
+	for i := range group.Members {
+		member := &group.Members[i]
+		if member.DraftID != draftID || member.Closed {
+			continue
+		}
+		total, err := group.Total.Sub(member.Sum)
+		if err != nil {
+			return common.NewError(errInvalidSum, "The sum of draft " + draftID + " can not be removed from group " + group.GroupID + ": " + err.Error())
+		}
+		group.Total = total
+		member.Closed = true
+	}
+	return nil
+}
+
+//涉及的所有汇票ID
+func trancheIDs(drafts []trancheDraft) []string {
+	var ids []string
+	for _, draft := range drafts {
+		ids = append(ids, draft.ID)
+	}
+	return ids
+}
+
+//汇票事件名称，链下系统按名称订阅
+const (
+	eventDraftCreated = "DraftCreated"	//发行
+	eventDraftTransferred = "DraftTransferred"	//转账平账成功，所属机构变更
+	eventDraftReconcileRequested = "DraftReconcileRequested"	//申请手工平账，Reason为原因码
+	eventDraftReconciled = "DraftReconciled"	//手工平账复核通过，Reason为原因码
+	eventDraftMismatch = "DraftMismatch"	//转账信息与汇票不匹配，Reason为不匹配原因
+	eventDraftOverdue = "DraftOverdue"	//转账平账成功但晚于计划时间，内容与DraftTransferred相同
+	eventDraftPartiallyPaid = "DraftPartiallyPaid"	//部分付款，所属机构不变
+	eventDraftReverseRequested = "DraftReverseRequested"	//申请冲正，等待另一方同意
+	eventDraftReversed = "DraftReversed"	//冲正，所属机构恢复为转账前的机构
+	eventDraftSettled = "DraftSettled"	//汇票到达最终到账机构，结清
+	eventDraftCancelled = "DraftCancelled"	//发行机构作废汇票
+	eventDraftExpired = "DraftExpired"	//计划路径最后日期已过仍未结清，Drafts为本次过期的所有汇票
+	eventDraftBatchReconciled = "DraftBatchReconciled"	//批量对账，Drafts为所有行涉及的汇票
+)
+
+//事件内容格式版本，draftEvent的字段有不兼容的变化时加1
+const draftEventSchemaVersion = 1
+
+//汇票事件内容，以json格式通过SetEvent发出
+//fabric每个交易只能发出一个事件，一次操作涉及多张汇票时都放在Drafts中，第一个为调用时传入的汇票
+type draftEvent struct {
+	SchemaVersion int 	//事件内容格式版本
+	Type string 	//事件名称
+	TxID string 	//交易ID
+	Drafts []string 	//涉及的汇票ID
+	PrevOwner string 	//变更前所属机构ID
+	NewOwner string 	//变更后所属机构ID
+	Reason string 	//不匹配原因
+	MismatchCode string `json:",omitempty"`	//不匹配码
+	Overdue bool 	//是否逾期
+}
+
+//发出汇票事件
+func emitDraftEvent(stub shim.ChaincodeStubInterface, event draftEvent) error {
+	event.SchemaVersion = draftEventSchemaVersion
+	event.TxID = stub.GetTxID()
+
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return stub.SetEvent(event.Type, b)
+}
+
+//计划路径和实际路径中的日期格式
+const dateLayout = "20060102"
+
+//交易时间按北京时间取日期
+var chinaTime = time.FixedZone("CST", 8*3600)
+
+//根据交易时间生成实际路径节点
+//交易日期晚于计划截止日期为逾期，截止日期当天转账不算逾期
+func newTruePathInfo(stub shim.ChaincodeStubInterface, account string, planTime string, bankTime string) (InfoStruct, error) {
+	var truePathInfo InfoStruct
+
+	planDate, err := time.ParseInLocation(dateLayout, planTime, chinaTime)
+	if err != nil {
+		return truePathInfo, common.NewError(errInvalidPlanPath, "The plan time " + planTime + " is not a date of format YYYYMMDD")
+	}
+
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return truePathInfo, errors.New("Failed to get transaction timestamp")
+	}
+	txTime := time.Unix(ts.Seconds, int64(ts.Nanos)).In(chinaTime)
+	txDate := time.Date(txTime.Year(), txTime.Month(), txTime.Day(), 0, 0, 0, 0, chinaTime)
+
+	truePathInfo.Account = account
+	truePathInfo.Time = txDate.Format(dateLayout)
+	truePathInfo.BankTime = bankTime
+	if txDate.After(planDate) {
+		truePathInfo.Overdue = true
+		truePathInfo.DaysLate = int(txDate.Sub(planDate).Hours() / 24)
+	}
+	return truePathInfo, nil
+}
+
+//交易日期（北京时间），格式为YYYYMMDD
+func txDate(stub shim.ChaincodeStubInterface) (string, error) {
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return "", errors.New("Failed to get transaction timestamp")
+	}
+	return time.Unix(ts.Seconds, int64(ts.Nanos)).In(chinaTime).Format(dateLayout), nil
+}
+
+//汇票历史以汇票ID、交易时间、交易ID为组合键的属性存储，只追加不修改
+const keyDraftHistory = "DraftHistory"
+
+//汇票历史结构体，每次发行、转账、平账、状态变更都追加一条
+type historyStruct struct {
+	TxID string 	//交易ID
+	Time string 	//交易时间
+	Operator string 	//操作人编号
+	Action string 	//操作：create发行，transfer转账，payment部分付款，update平账，reverse冲正，status状态变更，settle结清，cancel作废，expire过期
+	PrevOwner string 	//变更前汇票所属机构ID
+	NewOwner string 	//变更后汇票所属机构ID
+	Status string 	//变更后的状态码
+	MismatchCode string `json:",omitempty"`	//不匹配码
+}
+
+//追加一条汇票历史，交易时间以纳秒补齐20位作为键的一部分，按键的顺序遍历即按时间排序
+func recordHistory(stub shim.ChaincodeStubInterface, draftID string, action string, operator string, prevOwner string, draftInfo draftInfoStruct) error {
+	var history historyStruct
+
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return errors.New("Failed to get transaction timestamp")
+	}
+	txTime := time.Unix(ts.Seconds, int64(ts.Nanos)).UTC()
+
+	history.TxID = stub.GetTxID()
+	history.Time = txTime.Format(time.RFC3339)
+	history.Operator = operator
+	history.Action = action
+	history.PrevOwner = prevOwner
+	history.NewOwner = draftInfo.Owner
+	history.Status = draftInfo.Status
+	history.MismatchCode = draftInfo.MismatchCode
+
+	key, err := common.CreateCompositeKey(stub, keyDraftHistory, draftID, fmt.Sprintf("%020d", txTime.UnixNano()), history.TxID)
+	if err != nil {
+		return err
+	}
+	return common.PutJSON(stub, key, history)
+}
+
+
+//路由模板以模板名称为组合键的属性存储
+const keyRouteTemplate = "RouteTemplate"
+
+//路由步骤，计划路径中对应位置的节点由该角色的机构持有
+type routeStepStruct struct {
+	Role string 	//机构角色
+	Account string `json:",omitempty"`	//账户，不填写时由发行时的计划路径决定
+}
+
+//路由模板，汇票依次经过各步骤的机构，最后一步为最终到账机构
+type routeTemplateStruct struct {
+	Name string 	//模板名称
+	Steps []routeStepStruct 	//路由步骤，按转账顺序
+	FeeTolerance money.Money 	//手续费容差，每个节点累计付款与应付金额相差不超过该金额即视为付清，默认模板为0
+}
+
+//默认路由模板，发行时没有指定模板的汇票按发行机构角色使用，与原来按机构类型计算计划路径位置的规则一致
+//县发行：县→SPV→项目公司；省、ICBC发行：省或ICBC→有限合伙→SPV→项目公司
+var defaultRouteTemplates = map[string]routeTemplateStruct{
+	common.RoleCounty: {Name: "default-county", Steps: []routeStepStruct{{Role: common.RoleCounty}, {Role: common.RoleSPV}, {Role: common.RoleProjectCompany}}},
+	common.RoleProvince: {Name: "default-province", Steps: []routeStepStruct{{Role: common.RoleProvince}, {Role: common.RolePartnership}, {Role: common.RoleSPV}, {Role: common.RoleProjectCompany}}},
+	common.RoleICBC: {Name: "default-icbc", Steps: []routeStepStruct{{Role: common.RoleICBC}, {Role: common.RolePartnership}, {Role: common.RoleSPV}, {Role: common.RoleProjectCompany}}},
+}
+
+//机构角色在路由中的位置，即transfer时出账账户在PlanPath中的索引，收款账户为下一个索引
+func (r routeTemplateStruct) stepIndex(role string) (int, bool) {
+	for i, step := range r.Steps {
+		if step.Role == role {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+//读取路由模板，名称为空时使用发行机构角色对应的默认模板
+func getRouteTemplate(stub shim.ChaincodeStubInterface, name string, initiatorRole string) (routeTemplateStruct, error) {
+	var route routeTemplateStruct
+
+	if name == "" {
+		route, ok := defaultRouteTemplates[initiatorRole]
+		if !ok {
+			return route, errors.New("There is no default route for initiator role " + initiatorRole)
+		}
+		return route, nil
+	}
+	for _, defaultRoute := range defaultRouteTemplates {
+		if defaultRoute.Name == name {
+			return defaultRoute, nil
+		}
+	}
+
+	key, err := common.CreateCompositeKey(stub, keyRouteTemplate, name)
+	if err != nil {
+		return route, err
+	}
+	found, err := common.GetJSON(stub, key, &route)
+	if err != nil {
+		return route, err
+	}
+	if !found {
+		return route, errors.New("The route " + name + " is not found")
+	}
+	return route, nil
+}
+
+//设置路由模板 传入参数有3个或4个：模板名称，路由步骤（json数组，每一步包含机构角色和可选的账户），手续费容差（可选，默认为0），操作人编号
+//模板一经设置不能修改，已发行的汇票按发行时的模板转账；默认模板的名称不能使用
+func (t *SimpleChaincode) setRouteTemplate(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	var route routeTemplateStruct
+
+	err := common.CheckArgs(args, 3, 4)
+	if err != nil {
+		return nil, err
+	}
+
+	route.Name = args[0]
+	if route.Name == "" {
+		return nil, common.NewError(errInvalidRoute, "The route name is empty")
+	}
+	err = json.Unmarshal([]byte(args[1]), &route.Steps)
+	if err != nil {
+		return nil, common.NewError(errInvalidRoute, "The route steps are not valid json: " + err.Error())
+	}
+	//至少有发行机构和最终到账机构两步，同一角色只能出现一次
+	if len(route.Steps) < 2 {
+		return nil, common.NewError(errInvalidRoute, "The route " + route.Name + " needs at least 2 steps")
+	}
+	route.FeeTolerance = money.New(0, "")
+	if len(args) == 4 {
+		route.FeeTolerance, err = money.Parse(args[2])
+		if err != nil || route.FeeTolerance.Minor < 0 {
+			return nil, common.NewError(errInvalidSum, "The fee tolerance " + args[2] + " is not a valid amount")
+		}
+	}
+	roles := make(map[string]bool)
+	for i, step := range route.Steps {
+		if step.Role == "" || roles[step.Role] {
+			return nil, common.NewError(errInvalidRoute, "The role of step " + strconv.Itoa(i) + " is empty or repeated")
+		}
+		roles[step.Role] = true
+	}
+
+	for _, defaultRoute := range defaultRouteTemplates {
+		if defaultRoute.Name == route.Name {
+			return nil, common.NewError(errInvalidRoute, "The route " + route.Name + " is a default route")
+		}
+	}
+	routeKey, err := common.CreateCompositeKey(stub, keyRouteTemplate, route.Name)
+	if err != nil {
+		return nil, common.NewError(errInvalidRoute, "The route name " + route.Name + " is incorrect: " + err.Error())
+	}
+	routeByte, err := stub.GetState(routeKey)
+	if err != nil {
+		return nil, errors.New("Failed to get state")
+	}
+	if routeByte != nil {
+		return nil, common.NewError(errInvalidRoute, "The route " + route.Name + " already exists")
+	}
+
+	return nil, common.PutJSON(stub, routeKey, route)
+}
+
+//权限表：函数名 → 允许调用的机构角色
+//transfer和update只能由汇票当前所属机构的开户银行调用，与汇票有关，在函数内部判断；setOrgRegistry、setProjectChaincode只能由管理员调用一次
+//路由模板由发行机构设置；settle、reconcileBatch由汇票当前所属机构的开户银行调用，cancel由发行机构调用，approveUpdate由汇票当前所属机构调用，reverseTransfer由转账前后的所属机构调用，在函数内部判断
+var permissions = map[string][]string{
+	"create": {common.RoleCounty, common.RoleProvince, common.RoleICBC},
+	"transfer": nil,
+	"update": nil,
+	"setOrgRegistry": {common.RoleAdmin},
+	"setRouteTemplate": {common.RoleCounty, common.RoleProvince, common.RoleICBC},
+	"settle": nil,
+	"cancel": nil,
+	"reconcileBatch": nil,
+	"approveUpdate": nil,
+	"reverseTransfer": nil,
+	"expireOverdue": {common.RoleCounty, common.RoleProvince, common.RoleICBC},
+	"setProjectChaincode": {common.RoleAdmin},
+	"reindexDrafts": {common.RoleCounty, common.RoleProvince, common.RoleICBC},
+}
+
+//校验操作人是否为汇票当前所属机构的开户银行
+func checkOwnerBank(stub shim.ChaincodeStubInterface, owner string, operator string) error {
+	ownerInfo, err := common.GetOrgInfo(stub, owner)
+	if err != nil {
+		return err
+	}
+	if ownerInfo.Bank == "" || ownerInfo.Bank != operator {
+		return errors.New("The operator " + operator + " is not the bank of draft owner " + owner)
+	}
+	return nil
+}
+
+//校验变更后的所属机构：必须是有效的注册机构，角色为汇票路由中当前节点的下一步，并且account是该机构的银行账户
+//转账时account为银行流水中的收款账户，手工平账时为计划路径中下一个节点的账户
+func checkNewOwner(stub shim.ChaincodeStubInterface, draft trancheDraft, newOwnerID string, account string) error {
+	if draft.Index + 1 >= len(draft.Route.Steps) {
+		return common.NewError(errInvalidOwner, "The draft " + draft.ID + " can not be transferred after step " + strconv.Itoa(draft.Index) + " of route " + draft.Route.Name)
+	}
+	nextRole := draft.Route.Steps[draft.Index + 1].Role
+
+	newOwnerInfo, err := common.GetOrgInfo(stub, newOwnerID)
+	if err != nil {
+		return common.NewError(errInvalidOwner, "The new owner " + newOwnerID + " is not a registered organization: " + err.Error())
+	}
+	if newOwnerInfo.Role != nextRole {
+		return common.NewError(errInvalidOwner, "The new owner " + newOwnerID + " is not the " + nextRole + " of route " + draft.Route.Name)
+	}
+	for _, ownerAccount := range newOwnerInfo.Accounts {
+		if strings.EqualFold(ownerAccount, account) {
+			return nil
+		}
+	}
+	return common.NewError(errInvalidOwner, "The account " + account + " does not belong to the new owner " + newOwnerID)
+}
+
+//汇票错误码
+const (
+	errInvalidDraftID = "INVALID_DRAFT_ID"	//汇票ID格式不正确
+	errInvalidDraftInfo = "INVALID_DRAFT_INFO"	//汇票信息不是合法的json
+	errInitiatorMismatch = "INITIATOR_MISMATCH"	//发行机构与汇票ID最后一位不符
+	errInvalidSum = "INVALID_SUM"	//金额格式不正确或不是正数
+	errInvalidPlanPath = "INVALID_PLAN_PATH"	//计划路径为空或长度不够
+	errDraftExists = "DRAFT_EXISTS"	//汇票ID已存在
+	errInvalidRoute = "INVALID_ROUTE"	//路由模板不存在或与发行机构不符
+	errInvalidState = "INVALID_STATE"	//汇票当前状态不允许该操作
+	errInvalidReason = "INVALID_REASON"	//手工平账原因码不正确或没有说明
+	errInvalidOwner = "INVALID_OWNER"	//变更后的所属机构未注册、已停用、不是路由中的下一步或不持有收款账户
+)
+
+//判断汇票ID是否符合规则：九位阿拉伯数字，最后一位为1、2、3
+func isDraftID(draftID string) bool {
+	if len(draftID) != 9 {
+		return false
+	}
+	for i := 0; i < len(draftID); i++ {
+		if draftID[i] < '0' || draftID[i] > '9' {
+			return false
+		}
+	}
+	lastNum := draftID[len(draftID)-1]
+	return lastNum == '1' || lastNum == '2' || lastNum == '3'
+}
+
+//根据汇票ID最后一位判断发行机构的角色是否正确 1县 2省 3ICBC
+func isDraftInitiator(draftID string, initiatorRole string) bool {
+	switch draftID[len(draftID)-1] {
+	case '1':
+		return initiatorRole == common.RoleCounty
+	case '2':
+		return initiatorRole == common.RoleProvince
+	case '3':
+		return initiatorRole == common.RoleICBC
+	}
+	return false
+}
+
+//校验新发行汇票的信息：发行机构与汇票ID相符，金额为数字，最终到账机构和计划路径与路由模板相符，关联的项目存在
+func validateDraftInfo(stub shim.ChaincodeStubInterface, draftID string, draftInfo *draftInfoStruct) error {
+	initiatorRole, err := common.GetOrgRole(stub, draftInfo.Initiator)
+	if err != nil {
+		return err
+	}
+	if !isDraftInitiator(draftID, initiatorRole) {
+		return common.NewError(errInitiatorMismatch, "The initiator " + draftInfo.Initiator + " does not match the draftID " + draftID)
+	}
+	if !draftInfo.Sum.IsPositive() {
+		return common.NewError(errInvalidSum, "The sum " + draftInfo.Sum.String() + " must be positive")
+	}
+	if draftInfo.Target == "" {
+		return common.NewError(errInvalidDraftInfo, "The target is empty")
+	}
+	//新发行的汇票如果没有填写所属机构，则所属机构为发行机构
+	if draftInfo.Owner == "" {
+		draftInfo.Owner = draftInfo.Initiator
+	}
+	ownerRole, err := common.GetOrgRole(stub, draftInfo.Owner)
+	if err != nil {
+		return err
+	}
+
+	//计划路径的每个节点对应路由模板中的一步，模板中指定了账户的节点账户必须一致
+	route, err := getRouteTemplate(stub, draftInfo.Route, initiatorRole)
+	if err != nil {
+		return common.NewError(errInvalidRoute, err.Error())
+	}
+	if route.Steps[0].Role != initiatorRole {
+		return common.NewError(errInvalidRoute, "The route " + route.Name + " does not start with the initiator role " + initiatorRole)
+	}
+	//最终到账机构必须是有效的注册机构，角色为路由的最后一步，否则汇票到达最后一步后无法结清
+	targetRole, err := common.GetOrgRole(stub, draftInfo.Target)
+	if err != nil {
+		return common.NewError(errInvalidDraftInfo, "The target " + draftInfo.Target + " is not a registered organization: " + err.Error())
+	}
+	lastRole := route.Steps[len(route.Steps) - 1].Role
+	if targetRole != lastRole {
+		return common.NewError(errInvalidDraftInfo, "The target " + draftInfo.Target + " is not the " + lastRole + " of route " + route.Name)
+	}
+	index, ok := route.stepIndex(ownerRole)
+	if !ok || index + 1 >= len(route.Steps) {
+		return common.NewError(errInvalidDraftInfo, "The owner " + draftInfo.Owner + " can not hold a draft on route " + route.Name)
+	}
+	if len(draftInfo.PlanPath) != len(route.Steps) {
+		return common.NewError(errInvalidPlanPath, "The planPath needs " + strconv.Itoa(len(route.Steps)) + " nodes for route " + route.Name)
+	}
+	for i, node := range draftInfo.PlanPath {
+		if node.Account == "" {
+			return common.NewError(errInvalidPlanPath, "The account of planPath node " + strconv.Itoa(i) + " is empty")
+		}
+		if route.Steps[i].Account != "" && !strings.EqualFold(route.Steps[i].Account, node.Account) {
+			return common.NewError(errInvalidPlanPath, "The account of planPath node " + strconv.Itoa(i) + " does not match route " + route.Name)
+		}
+		if _, err := time.Parse(dateLayout, node.Time); err != nil {
+			return common.NewError(errInvalidPlanPath, "The time of planPath node " + strconv.Itoa(i) + " is not a date of format YYYYMMDD")
+		}
+	}
+	if draftInfo.ProjectID != "" {
+		return checkProject(stub, draftInfo.ProjectID)
+	}
+	return nil
+}
+
+//查询，与修改账本的函数一样通过Invoke调用
+func (t *SimpleChaincode) query(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+	if function == "queryHistory" {
+		return t.queryHistory(stub, args)
+	}else if function == "queryGroup" {
+		return t.queryGroup(stub, args)
+	}else if function == "queryRoute" {
+		return t.queryRoute(stub, args)
+	}else if function == "queryByStatus" || function == "queryByState" {
+		//queryByState是按状态查询原来的函数名，保留兼容
+		return queryByIndex(stub, keyDraftStatus, args)
+	}else if function == "queryByOwner" {
+		return queryByIndex(stub, keyDraftOwner, args)
+	}else if function == "queryByInitiator" {
+		return queryByIndex(stub, keyDraftInitiator, args)
+	}else if function == "queryByTarget" {
+		return queryByIndex(stub, keyDraftTarget, args)
+	}else if function == "getDraft" {
+		return t.getDraft(stub, args)
+	}
+
+	return nil, errors.New("Invalid query function name. Expecting \"getDraft\", \"queryHistory\", \"queryGroup\", \"queryRoute\", \"queryByStatus\", \"queryByState\", \"queryByOwner\", \"queryByInitiator\" or \"queryByTarget\"")
+}
+
+//汇票查询结果
+type draftDocument struct {
+	SchemaVersion int 	//查询结果格式版本
+	DraftID string 	//汇票ID
+	Draft draftInfoStruct 	//汇票信息
+}
+
+//查询汇票 传入参数有1个：汇票ID
+func (t *SimpleChaincode) getDraft(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) != 1 {
+		return nil, errors.New("Incorrect number of arguments. Expecting draftID to query")
+	}
+
+	draftInfo, err := getDraftInfo(stub, args[0])
+	if err != nil {
+		jsonResp := "{\"Error\":\"Failed to get draft " + args[0] + ": " + err.Error() + "\"}"
+		return nil, errors.New(jsonResp)
+	}
+
+	return json.Marshal(draftDocument{SchemaVersion: common.QuerySchemaVersion, DraftID: args[0], Draft: draftInfo})
+}
+
+//查询汇票历史 传入参数有1个：汇票ID，按时间顺序返回该汇票的所有历史（json数组）
+func (t *SimpleChaincode) queryHistory(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	var histories []historyStruct
+
+	if len(args) != 1 {
+		return nil, errors.New("Incorrect number of arguments. Expecting draftID to query")
+	}
+
+	iter, err := stub.GetStateByPartialCompositeKey(keyDraftHistory, []string{args[0]})
+	if err != nil {
+		return nil, errors.New("Failed to get state")
+	}
+	defer iter.Close()
+
+	histories = []historyStruct{}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		var history historyStruct
+		err = json.Unmarshal(kv.Value, &history)
+		if err != nil {
+			return nil, err
+		}
+		histories = append(histories, history)
+	}
+
+	return json.Marshal(histories)
+}
+
+//汇票组查询结果，包含汇票组和组内所有汇票的信息
+type groupQueryResult struct {
+	Group draftGroupStruct 	//汇票组
+	Drafts map[string]draftInfoStruct 	//组内汇票，键为汇票ID
+}
+
+//查询汇票组 传入参数有1个：汇票组ID（汇票ID的前八位），返回汇票组和组内所有汇票
+func (t *SimpleChaincode) queryGroup(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	var result groupQueryResult
+
+	if len(args) != 1 {
+		return nil, errors.New("Incorrect number of arguments. Expecting groupID to query")
+	}
+
+	group, err := getDraftGroup(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if group == nil {
+		jsonResp := "{\"Error\":\"Nil group for " + args[0] + "\"}"
+		return nil, errors.New(jsonResp)
+	}
+
+	result.Group = *group
+	result.Drafts = make(map[string]draftInfoStruct)
+	for _, member := range group.Members {
+		draftInfo, err := getDraftInfo(stub, member.DraftID)
+		if err != nil {
+			return nil, err
+		}
+		result.Drafts[member.DraftID] = draftInfo
+	}
+
+	return json.Marshal(result)
+}
+
+//查询路由模板 传入参数有1个：模板名称，默认模板为default-county、default-province、default-icbc
+func (t *SimpleChaincode) queryRoute(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) != 1 {
+		return nil, errors.New("Incorrect number of arguments. Expecting route name to query")
+	}
+
+	route, err := getRouteTemplate(stub, args[0], "")
+	if err != nil {
+		jsonResp := "{\"Error\":\"" + err.Error() + "\"}"
+		return nil, errors.New(jsonResp)
+	}
+	return json.Marshal(route)
+}
+
+//按索引查询的一张汇票
+type draftQueryResult struct {
+	DraftID string 	//汇票ID
+	Draft draftInfoStruct 	//汇票信息
+}
+
+//按索引查询的一页结果
+type draftPage struct {
+	Drafts []draftQueryResult 	//本页汇票，按汇票ID排序
+	Bookmark string `json:",omitempty"`	//fabric分页查询返回的书签，还有下一页时作为下一次查询的参数，没有下一页时为空
+}
+
+//分页大小
+const (
+	defaultPageSize = 20
+	maxPageSize = 100
+)
+
+//按二级索引分页查询汇票，queryByStatus（queryByState）、queryByOwner、queryByInitiator、queryByTarget共用
+//传入参数有1到3个：索引值（状态码或机构ID），每页数量（可选，默认20，最多100），上一页返回的Bookmark（可选）
+//原来没有索引的汇票需要先调用reindexDrafts
+func queryByIndex(stub shim.ChaincodeStubInterface, indexKey string, args []string) ([]byte, error) {
+	var page draftPage
+
+	if len(args) < 1 || len(args) > 3 {
+		return nil, errors.New("Incorrect number of arguments. Expecting value, page size and bookmark to query")
+	}
+	if indexKey == keyDraftStatus {
+		if _, ok := map[string]bool{statusIssued: true, statusInTransit: true, statusMismatch: true, statusPartiallyPaid: true, statusReconciled: true, statusSettled: true, statusCancelled: true, statusExpired: true}[args[0]]; !ok {
+			return nil, common.NewError(errInvalidState, "The state " + args[0] + " is incorrect")
+		}
+	}
+	pageSize := defaultPageSize
+	if len(args) >= 2 && args[1] != "" {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n <= 0 || n > maxPageSize {
+			return nil, errors.New("The page size " + args[1] + " must be between 1 and " + strconv.Itoa(maxPageSize))
+		}
+		pageSize = n
+	}
+
+	//由fabric按组合键分页，每次只读取一页；Bookmark是下一页第一个索引键，必须是本次查询的索引值下的键
+	bookmark := ""
+	if len(args) == 3 {
+		bookmark = args[2]
+	}
+	prefix, err := common.CreateCompositeKey(stub, indexKey, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if bookmark != "" && !strings.HasPrefix(bookmark, prefix) {
+		return nil, errors.New("The bookmark does not belong to this query")
+	}
+	iter, metadata, err := stub.GetStateByPartialCompositeKeyWithPagination(indexKey, []string{args[0]}, int32(pageSize), bookmark)
+	if err != nil {
+		return nil, errors.New("Failed to get state: " + err.Error())
+	}
+	defer iter.Close()
+
+	page.Drafts = []draftQueryResult{}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, attributes, err := stub.SplitCompositeKey(kv.Key)
+		if err != nil {
+			return nil, err
+		}
+		draftID := attributes[1]
+		draftInfo, err := getDraftInfo(stub, draftID)
+		if err != nil {
+			return nil, err
+		}
+		page.Drafts = append(page.Drafts, draftQueryResult{DraftID: draftID, Draft: draftInfo})
+	}
+	page.Bookmark = metadata.Bookmark
+
+	return json.Marshal(page)
+}
+
+func main() {
+	err := shim.Start(new(SimpleChaincode))
+	if err != nil {
+		fmt.Printf("Error starting Simple chaincode: %s", err)
+	}
+}
@@ -0,0 +1,1415 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+//数字汇票链码的单元测试，链码运行在内存中的stub上，机构信息来自testdata/orgs.json
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/xyjxyjxyj/MySC/common"
+	"github.com/xyjxyjxyj/MySC/mockstub"
+	"github.com/xyjxyjxyj/MySC/money"
+)
+
+//testdata/orgs.json中的机构，所有机构的开户银行都是ICBC
+const (
+	orgCounty = "10101"	//县
+	orgSPV = "10201"	//SPV
+	orgProvince = "20003"	//省
+	orgPartnership = "20005"	//有限合伙
+	orgICBC = "20006"	//ICBC
+	orgProjectCompany = "3001"	//项目公司
+	orgBank = orgICBC	//开户银行，转账和手工平账的操作人
+)
+
+//各机构的银行账户
+var testAccounts = map[string]string{
+	orgCounty: "6222000010101",
+	orgSPV: "6222000010201",
+	orgProvince: "6222000020003",
+	orgPartnership: "6222000020005",
+	orgICBC: "6222000020006",
+	orgProjectCompany: "6222000003001",
+}
+
+//测试用汇票组12345678：县、省、ICBC各发行一张，最终到账机构都是项目公司
+//同一个节点的计划时间相同，有限合伙、SPV转账时同时涉及多张汇票
+const (
+	draftCounty = "123456781"
+	draftProvince = "123456782"
+	draftICBC = "123456783"
+)
+
+//计划路径节点：机构ID和转账截止日期
+type testStep struct {
+	Org string
+	Time string
+}
+
+//测试用汇票的发行机构、金额和计划路径
+var testDrafts = map[string]struct {
+	Initiator string
+	Sum string
+	Plan []testStep
+}{
+	draftCounty: {orgCounty, "1000", []testStep{{orgCounty, "20170301"}, {orgSPV, "20170310"}, {orgProjectCompany, "20170320"}}},
+	draftProvince: {orgProvince, "2000", []testStep{{orgProvince, "20170301"}, {orgPartnership, "20170305"}, {orgSPV, "20170310"}, {orgProjectCompany, "20170320"}}},
+	draftICBC: {orgICBC, "3000", []testStep{{orgICBC, "20170301"}, {orgPartnership, "20170305"}, {orgSPV, "20170310"}, {orgProjectCompany, "20170320"}}},
+}
+
+//测试用汇票的发行信息
+func testDraftInfo(draftID string) draftInfoStruct {
+	var draftInfo draftInfoStruct
+
+	draft := testDrafts[draftID]
+	draftInfo.Initiator = draft.Initiator
+	draftInfo.Target = orgProjectCompany
+	draftInfo.Sum, _ = money.Parse(draft.Sum)
+	for _, step := range draft.Plan {
+		draftInfo.PlanPath = append(draftInfo.PlanPath, InfoStruct{Account: testAccounts[step.Org], Time: step.Time})
+	}
+	return draftInfo
+}
+
+//读取testdata/orgs.json中的机构，部署后修改返回的机构信息（例如停用机构）立即生效
+func loadTestRegistry(t *testing.T) *mockstub.OrgRegistry {
+	registry, err := mockstub.LoadOrgRegistry("../../testdata/orgs.json")
+	if err != nil {
+		t.Fatalf("load organizations: %v", err)
+	}
+	return registry
+}
+
+//由ICBC部署数字汇票链码和机构注册链码，ICBC的证书为数字汇票链码的管理员
+func deployTestStub(t *testing.T, registry *mockstub.OrgRegistry) *mockstub.MockStub {
+	stub := mockstub.NewMockStub("szhp", new(SimpleChaincode))
+	stub.MockPeerChaincode("zzjg", mockstub.NewMockStub("zzjg", registry))
+	stub.SetCaller(orgICBC)
+	_, err := stub.MockInit("init", []string{orgICBC})
+	if err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	return stub
+}
+
+//部署并设置机构注册链码
+func newTestStub(t *testing.T) *mockstub.MockStub {
+	return newTestStubWithRegistry(t, loadTestRegistry(t))
+}
+
+func newTestStubWithRegistry(t *testing.T, registry *mockstub.OrgRegistry) *mockstub.MockStub {
+	stub := deployTestStub(t, registry)
+	mustInvoke(t, stub, "setOrgRegistry", orgICBC, "zzjg")
+	return stub
+}
+
+//测试用的项目链码，代替项目链码提供getProject和expireFundProgress
+//标记过期的汇票以汇票ID为键、项目ID为值写入账本，与调用方的交易一起提交
+type testProjects struct {
+	Projects map[string]bool 	//已创建的项目，测试中可以删除
+}
+
+func (p *testProjects) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	return shim.Success(nil)
+}
+
+func (p *testProjects) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	function, args := stub.GetFunctionAndParameters()
+	if !p.Projects[args[0]] {
+		return shim.Error("{\"Error\":\"Nil project for " + args[0] + "\"}")
+	}
+	if function == "expireFundProgress" {
+		err := stub.PutState(args[1], []byte(args[0]))
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+	return shim.Success(nil)
+}
+
+//设置项目链码，其中只有项目P001
+func setTestProjects(t *testing.T, stub *mockstub.MockStub) (*testProjects, *mockstub.MockStub) {
+	projects := &testProjects{Projects: map[string]bool{"P001": true}}
+	projectStub := mockstub.NewMockStub("xm", projects)
+	stub.MockPeerChaincode("xm", projectStub)
+	mustInvoke(t, stub, "setProjectChaincode", orgICBC, "xm")
+	return projects, projectStub
+}
+
+//发行测试用的三张汇票
+func newTestGroup(t *testing.T) *mockstub.MockStub {
+	return issueTestGroup(t, newTestStub(t))
+}
+
+func issueTestGroup(t *testing.T, stub *mockstub.MockStub) *mockstub.MockStub {
+	for _, draftID := range []string{draftCounty, draftProvince, draftICBC} {
+		b, err := json.Marshal(testDraftInfo(draftID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		mustInvoke(t, stub, "create", testDrafts[draftID].Initiator, draftID, string(b))
+	}
+	return stub
+}
+
+//以operator的身份调用链码，operator作为最后一个参数
+func invoke(stub *mockstub.MockStub, function string, operator string, args ...string) ([]byte, error) {
+	stub.SetCaller(operator)
+	return stub.MockInvoke(function, append(args, operator))
+}
+
+func mustInvoke(t *testing.T, stub *mockstub.MockStub, function string, operator string, args ...string) []byte {
+	result, err := invoke(stub, function, operator, args...)
+	if err != nil {
+		t.Fatalf("%s %v: %v", function, args, err)
+	}
+	return result
+}
+
+//由开户银行转账，to为变更后的所属机构，银行流水时间固定
+func transfer(stub *mockstub.MockStub, draftID string, to string, sum string, payAccount string, receiptAccount string) (transferResult, error) {
+	var result transferResult
+
+	b, err := invoke(stub, "transfer", orgBank, draftID, to, sum, payAccount, receiptAccount, "2017-03-01 10:00:00")
+	if err != nil {
+		return result, err
+	}
+	err = json.Unmarshal(b, &result)
+	return result, err
+}
+
+//按计划路径转账，必须完全匹配
+func mustTransfer(t *testing.T, stub *mockstub.MockStub, draftID string, from string, to string, sum string) {
+	result, err := transfer(stub, draftID, to, sum, testAccounts[from], testAccounts[to])
+	if err != nil {
+		t.Fatalf("transfer %s from %s to %s: %v", draftID, from, to, err)
+	}
+	if !result.Matched || result.Partial {
+		t.Fatalf("transfer %s from %s to %s: %+v", draftID, from, to, result)
+	}
+}
+
+//通过getDraft查询汇票
+func queryDraft(t *testing.T, stub *mockstub.MockStub, draftID string) draftInfoStruct {
+	var document draftDocument
+
+	b, err := stub.MockQuery("getDraft", []string{draftID})
+	if err != nil {
+		t.Fatalf("getDraft %s: %v", draftID, err)
+	}
+	err = json.Unmarshal(b, &document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return document.Draft
+}
+
+//最后一个成功的交易发出的汇票事件
+func lastEvent(t *testing.T, stub *mockstub.MockStub) draftEvent {
+	var event draftEvent
+
+	e := stub.LastEvent()
+	if e == nil {
+		t.Fatal("no event")
+	}
+	err := json.Unmarshal(e.Payload, &event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != e.Name {
+		t.Fatalf("event name %s does not match type %s", e.Name, event.Type)
+	}
+	return event
+}
+
+//错误码，不是带错误码的错误时返回空字符串
+func errorCode(err error) string {
+	var codedError common.CodedError
+
+	if err == nil || json.Unmarshal([]byte(err.Error()), &codedError) != nil {
+		return ""
+	}
+	return codedError.Code
+}
+
+func mustParse(s string) money.Money {
+	m, err := money.Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func TestCreate(t *testing.T) {
+	tests := []struct {
+		name string
+		draftID string
+		info func(*draftInfoStruct) 	//在testDraftInfo的基础上修改
+		raw string 	//直接使用的汇票信息，不为空时忽略info
+		operator string
+		exists bool 	//先发行一张相同的汇票
+		projects bool 	//先设置项目链码
+		wantErr bool
+		wantCode string
+	}{
+		{name: "county", draftID: draftCounty},
+		{name: "province", draftID: draftProvince},
+		{name: "icbc", draftID: draftICBC},
+		{name: "owner defaults to initiator", draftID: draftCounty, info: func(d *draftInfoStruct) { d.Owner = "" }},
+		{name: "short draft ID", draftID: "12345671", wantErr: true, wantCode: errInvalidDraftID},
+		{name: "draft ID ending with 4", draftID: "123456784", wantErr: true, wantCode: errInvalidDraftID},
+		{name: "initiator does not match draft ID", draftID: "123456782", info: func(d *draftInfoStruct) { *d = testDraftInfo(draftCounty) }, operator: orgCounty, wantErr: true, wantCode: errInitiatorMismatch},
+		{name: "invalid json", draftID: draftCounty, raw: "{", wantErr: true, wantCode: errInvalidDraftInfo},
+		{name: "invalid sum", draftID: draftCounty, raw: `{"Sum":"12x","Initiator":"10101"}`, wantErr: true, wantCode: errInvalidSum},
+		{name: "zero sum", draftID: draftCounty, info: func(d *draftInfoStruct) { d.Sum = money.New(0, "") }, wantErr: true, wantCode: errInvalidSum},
+		{name: "empty target", draftID: draftCounty, info: func(d *draftInfoStruct) { d.Target = "" }, wantErr: true, wantCode: errInvalidDraftInfo},
+		{name: "plan path too short", draftID: draftCounty, info: func(d *draftInfoStruct) { d.PlanPath = d.PlanPath[:2] }, wantErr: true, wantCode: errInvalidPlanPath},
+		{name: "plan date format", draftID: draftCounty, info: func(d *draftInfoStruct) { d.PlanPath[1].Time = "2017-03-10" }, wantErr: true, wantCode: errInvalidPlanPath},
+		{name: "unknown route", draftID: draftCounty, info: func(d *draftInfoStruct) { d.Route = "no-such-route" }, wantErr: true, wantCode: errInvalidRoute},
+		{name: "route name with a nil byte", draftID: draftCounty, info: func(d *draftInfoStruct) { d.Route = "default-county\x00x" }, wantErr: true, wantCode: errInvalidRoute},
+		{name: "target with a nil byte", draftID: draftCounty, info: func(d *draftInfoStruct) { d.Target = orgProjectCompany + "\x00zzz" }, wantErr: true, wantCode: errInvalidDraftInfo},
+		{name: "unregistered target", draftID: draftCounty, info: func(d *draftInfoStruct) { d.Target = "3999" }, wantErr: true, wantCode: errInvalidDraftInfo},
+		{name: "target is not the last step of the route", draftID: draftProvince, info: func(d *draftInfoStruct) { d.Target = orgSPV }, wantErr: true, wantCode: errInvalidDraftInfo},
+		{name: "project", draftID: draftCounty, info: func(d *draftInfoStruct) { d.ProjectID = "P001" }, projects: true},
+		{name: "project chaincode is not set", draftID: draftCounty, info: func(d *draftInfoStruct) { d.ProjectID = "P001" }, wantErr: true, wantCode: errInvalidDraftInfo},
+		{name: "unknown project", draftID: draftCounty, info: func(d *draftInfoStruct) { d.ProjectID = "P404" }, projects: true, wantErr: true, wantCode: errInvalidDraftInfo},
+		{name: "operator is not the initiator", draftID: draftCounty, operator: orgProvince, wantErr: true},
+		{name: "operator role has no permission", draftID: draftCounty, operator: orgSPV, wantErr: true},
+		{name: "duplicate", draftID: draftCounty, exists: true, wantErr: true, wantCode: errDraftExists},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := newTestStub(t)
+			if tt.projects {
+				setTestProjects(t, stub)
+			}
+
+			draftInfo := testDraftInfo(draftCounty)
+			if _, ok := testDrafts[tt.draftID]; ok {
+				draftInfo = testDraftInfo(tt.draftID)
+			}
+			if tt.info != nil {
+				tt.info(&draftInfo)
+			}
+			b, _ := json.Marshal(draftInfo)
+			info := string(b)
+			if tt.raw != "" {
+				info = tt.raw
+			}
+			operator := tt.operator
+			if operator == "" {
+				operator = draftInfo.Initiator
+			}
+			if tt.exists {
+				mustInvoke(t, stub, "create", operator, tt.draftID, info)
+			}
+
+			_, err := invoke(stub, "create", operator, tt.draftID, info)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				if code := errorCode(err); code != tt.wantCode {
+					t.Fatalf("error code = %q, want %q (%v)", code, tt.wantCode, err)
+				}
+				if !tt.exists {
+					if _, err := stub.MockQuery("getDraft", []string{tt.draftID}); err == nil {
+						t.Fatal("the draft is saved although create failed")
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			draft := queryDraft(t, stub, tt.draftID)
+			if draft.Status != statusIssued || draft.Owner != draftInfo.Initiator || draft.GroupID != "12345678" {
+				t.Fatalf("status %s owner %s group %s", draft.Status, draft.Owner, draft.GroupID)
+			}
+			if !draft.Outstanding.Equal(draftInfo.Sum) || draft.Paid.Minor != 0 {
+				t.Fatalf("paid %s outstanding %s", draft.Paid, draft.Outstanding)
+			}
+			event := lastEvent(t, stub)
+			if event.Type != eventDraftCreated || !reflect.DeepEqual(event.Drafts, []string{tt.draftID}) {
+				t.Fatalf("event %+v", event)
+			}
+		})
+	}
+}
+
+//转账前的准备：按顺序完成的转账
+type testTransfer struct {
+	DraftID string
+	From string
+	To string
+	Sum string
+}
+
+//把三张汇票都转到有限合伙、SPV之前需要的转账
+var (
+	toPartnership = []testTransfer{
+		{draftProvince, orgProvince, orgPartnership, "2000"},
+		{draftICBC, orgICBC, orgPartnership, "3000"},
+	}
+	toSPV = append([]testTransfer{{draftCounty, orgCounty, orgSPV, "1000"}}, append(toPartnership, testTransfer{draftProvince, orgPartnership, orgSPV, "5000"})...)
+)
+
+func TestTransferOwnerClasses(t *testing.T) {
+	tests := []struct {
+		name string
+		setup []testTransfer
+		transfer testTransfer
+		wantDrafts []string 	//涉及的汇票
+		wantOwners map[string]string 	//转账后每张汇票的所属机构
+	}{
+		{
+			name: "county 101xx",
+			transfer: testTransfer{draftCounty, orgCounty, orgSPV, "1000"},
+			wantDrafts: []string{draftCounty},
+			wantOwners: map[string]string{draftCounty: orgSPV, draftProvince: orgProvince, draftICBC: orgICBC},
+		},
+		{
+			name: "province 20003",
+			transfer: testTransfer{draftProvince, orgProvince, orgPartnership, "2000"},
+			wantDrafts: []string{draftProvince},
+			wantOwners: map[string]string{draftCounty: orgCounty, draftProvince: orgPartnership, draftICBC: orgICBC},
+		},
+		{
+			name: "icbc 20006",
+			transfer: testTransfer{draftICBC, orgICBC, orgPartnership, "3000"},
+			wantDrafts: []string{draftICBC},
+			wantOwners: map[string]string{draftCounty: orgCounty, draftProvince: orgProvince, draftICBC: orgPartnership},
+		},
+		{
+			name: "partnership 20005 moves the province and icbc drafts",
+			setup: toPartnership,
+			transfer: testTransfer{draftICBC, orgPartnership, orgSPV, "5000"},
+			wantDrafts: []string{draftICBC, draftProvince},
+			wantOwners: map[string]string{draftCounty: orgCounty, draftProvince: orgSPV, draftICBC: orgSPV},
+		},
+		{
+			name: "spv 102xx moves the whole group",
+			setup: toSPV,
+			transfer: testTransfer{draftProvince, orgSPV, orgProjectCompany, "6000"},
+			wantDrafts: []string{draftProvince, draftCounty, draftICBC},
+			wantOwners: map[string]string{draftCounty: orgProjectCompany, draftProvince: orgProjectCompany, draftICBC: orgProjectCompany},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := newTestGroup(t)
+			for _, s := range tt.setup {
+				mustTransfer(t, stub, s.DraftID, s.From, s.To, s.Sum)
+			}
+			before := make(map[string]draftInfoStruct)
+			for draftID := range testDrafts {
+				before[draftID] = queryDraft(t, stub, draftID)
+			}
+
+			result, err := transfer(stub, tt.transfer.DraftID, tt.transfer.To, tt.transfer.Sum, testAccounts[tt.transfer.From], testAccounts[tt.transfer.To])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !result.Matched || result.Partial || result.Overdue || result.Status != statusInTransit {
+				t.Fatalf("result %+v", result)
+			}
+			if result.PrevOwner != tt.transfer.From || result.NewOwner != tt.transfer.To || !reflect.DeepEqual(result.Drafts, tt.wantDrafts) {
+				t.Fatalf("result %+v", result)
+			}
+			if !result.Paid.Equal(mustParse(tt.transfer.Sum)) || result.Outstanding.Minor != 0 {
+				t.Fatalf("paid %s outstanding %s", result.Paid, result.Outstanding)
+			}
+
+			for draftID, owner := range tt.wantOwners {
+				draft := queryDraft(t, stub, draftID)
+				if draft.Owner != owner {
+					t.Errorf("draft %s owner = %s, want %s", draftID, draft.Owner, owner)
+				}
+				if owner == before[draftID].Owner {
+					if len(draft.TruePath) != len(before[draftID].TruePath) {
+						t.Errorf("draft %s is not part of the transfer but its true path changed", draftID)
+					}
+					continue
+				}
+				if draft.Status != statusInTransit || len(draft.TruePath) != len(before[draftID].TruePath) + 1 {
+					t.Errorf("draft %s status %s true path %d", draftID, draft.Status, len(draft.TruePath))
+					continue
+				}
+				node := draft.TruePath[len(draft.TruePath)-1]
+				if node.Account != testAccounts[tt.transfer.From] || node.Overdue {
+					t.Errorf("draft %s true path node %+v", draftID, node)
+				}
+				if !draft.Outstanding.Equal(draft.Sum) || draft.Paid.Minor != 0 {
+					t.Errorf("draft %s payment is not reset: paid %s outstanding %s", draftID, draft.Paid, draft.Outstanding)
+				}
+			}
+
+			event := lastEvent(t, stub)
+			if event.Type != eventDraftTransferred || event.PrevOwner != tt.transfer.From || event.NewOwner != tt.transfer.To || !reflect.DeepEqual(event.Drafts, tt.wantDrafts) {
+				t.Fatalf("event %+v", event)
+			}
+		})
+	}
+}
+
+//是否逾期用交易日期（北京时间）与计划路径中的截止日期比较，县汇票第一个节点截止日期为20170301
+func TestTransferOverdue(t *testing.T) {
+	tests := []struct {
+		name string
+		txTime time.Time
+		wantOverdue bool
+		wantDaysLate int
+		wantDate string
+	}{
+		{name: "before the plan date", txTime: time.Date(2017, 2, 20, 2, 0, 0, 0, time.UTC), wantDate: "20170220"},
+		{name: "on the plan date", txTime: time.Date(2017, 3, 1, 15, 59, 59, 0, time.UTC), wantDate: "20170301"},
+		{name: "next day in Beijing time", txTime: time.Date(2017, 3, 1, 16, 0, 0, 0, time.UTC), wantOverdue: true, wantDaysLate: 1, wantDate: "20170302"},
+		{name: "ten days late", txTime: time.Date(2017, 3, 11, 1, 0, 0, 0, time.UTC), wantOverdue: true, wantDaysLate: 10, wantDate: "20170311"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := newTestGroup(t)
+			stub.Time = tt.txTime
+
+			result, err := transfer(stub, draftCounty, orgSPV, "1000", testAccounts[orgCounty], testAccounts[orgSPV])
+			if err != nil {
+				t.Fatal(err)
+			}
+			//逾期依然平账
+			if !result.Matched || result.NewOwner != orgSPV || result.Status != statusInTransit {
+				t.Fatalf("result %+v", result)
+			}
+			if result.Overdue != tt.wantOverdue || result.DaysLate != tt.wantDaysLate {
+				t.Fatalf("overdue %v days late %d, want %v %d", result.Overdue, result.DaysLate, tt.wantOverdue, tt.wantDaysLate)
+			}
+
+			draft := queryDraft(t, stub, draftCounty)
+			node := draft.TruePath[len(draft.TruePath)-1]
+			if node.Overdue != tt.wantOverdue || node.DaysLate != tt.wantDaysLate || node.Time != tt.wantDate || node.BankTime != "2017-03-01 10:00:00" {
+				t.Fatalf("true path node %+v", node)
+			}
+
+			wantEvent := eventDraftTransferred
+			if tt.wantOverdue {
+				wantEvent = eventDraftOverdue
+			}
+			event := lastEvent(t, stub)
+			if event.Type != wantEvent || event.Overdue != tt.wantOverdue {
+				t.Fatalf("event %+v", event)
+			}
+		})
+	}
+}
+
+func TestTransferMismatch(t *testing.T) {
+	tests := []struct {
+		name string
+		setup []testTransfer
+		draftID string
+		owner string 	//汇票当前所属机构
+		next string 	//计划路径中的下一个机构
+		sum string
+		payAccount string
+		receiptAccount string
+		wantChecks []string 	//不匹配的检查项，第一个为汇票上记录的不匹配码
+		wantDrafts []string
+	}{
+		{name: "amount too high", draftID: draftCounty, owner: orgCounty, next: orgSPV, sum: "1000.01", wantChecks: []string{mismatchAmount}},
+		{name: "zero amount", draftID: draftCounty, owner: orgCounty, next: orgSPV, sum: "0", wantChecks: []string{mismatchAmount}},
+		{name: "negative amount", draftID: draftCounty, owner: orgCounty, next: orgSPV, sum: "-1000", wantChecks: []string{mismatchAmount}},
+		{name: "other currency", draftID: draftCounty, owner: orgCounty, next: orgSPV, sum: "1000 USD", wantChecks: []string{mismatchAmount}},
+		{name: "pay account", draftID: draftCounty, owner: orgCounty, next: orgSPV, sum: "1000", payAccount: testAccounts[orgProvince], wantChecks: []string{mismatchPayAccount}},
+		{name: "receipt account", draftID: draftCounty, owner: orgCounty, next: orgSPV, sum: "1000", receiptAccount: testAccounts[orgProjectCompany], wantChecks: []string{mismatchReceiptAccount}},
+		{name: "both accounts", draftID: draftCounty, owner: orgCounty, next: orgSPV, sum: "1000", payAccount: "1", receiptAccount: "2", wantChecks: []string{mismatchPayAccount, mismatchReceiptAccount}},
+		{name: "all checks", draftID: draftCounty, owner: orgCounty, next: orgSPV, sum: "2000", payAccount: "1", receiptAccount: "2", wantChecks: []string{mismatchAmount, mismatchPayAccount, mismatchReceiptAccount}},
+		{name: "partnership total", setup: toPartnership, draftID: draftProvince, owner: orgPartnership, next: orgSPV, sum: "5000.01", wantChecks: []string{mismatchAmount}, wantDrafts: []string{draftProvince, draftICBC}},
+		{name: "spv receipt account", setup: toSPV, draftID: draftCounty, owner: orgSPV, next: orgProjectCompany, sum: "6000", receiptAccount: testAccounts[orgPartnership], wantChecks: []string{mismatchReceiptAccount}, wantDrafts: []string{draftCounty, draftProvince, draftICBC}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := newTestGroup(t)
+			for _, s := range tt.setup {
+				mustTransfer(t, stub, s.DraftID, s.From, s.To, s.Sum)
+			}
+			payAccount := tt.payAccount
+			if payAccount == "" {
+				payAccount = testAccounts[tt.owner]
+			}
+			receiptAccount := tt.receiptAccount
+			if receiptAccount == "" {
+				receiptAccount = testAccounts[tt.next]
+			}
+			wantDrafts := tt.wantDrafts
+			if wantDrafts == nil {
+				wantDrafts = []string{tt.draftID}
+			}
+			code := tt.wantChecks[0]
+
+			//不匹配时保存不匹配状态，不返回错误
+			result, err := transfer(stub, tt.draftID, tt.next, tt.sum, payAccount, receiptAccount)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Matched || result.Status != statusMismatch || result.NewOwner != tt.owner || result.Reason != mismatchReasons[code] {
+				t.Fatalf("result %+v", result)
+			}
+			if !reflect.DeepEqual(result.FailedChecks, tt.wantChecks) || !reflect.DeepEqual(result.Drafts, wantDrafts) {
+				t.Fatalf("failed checks %v drafts %v", result.FailedChecks, result.Drafts)
+			}
+
+			for _, draftID := range wantDrafts {
+				draft := queryDraft(t, stub, draftID)
+				if draft.Owner != tt.owner || draft.Status != statusMismatch || draft.MismatchCode != code || draft.MismatchReason != mismatchReasons[code] {
+					t.Errorf("draft %s owner %s status %s code %s reason %s", draftID, draft.Owner, draft.Status, draft.MismatchCode, draft.MismatchReason)
+				}
+			}
+
+			event := lastEvent(t, stub)
+			if event.Type != eventDraftMismatch || event.MismatchCode != code || event.Reason != mismatchReasons[code] || !reflect.DeepEqual(event.Drafts, wantDrafts) {
+				t.Fatalf("event %+v", event)
+			}
+
+			//不匹配的汇票可以重新按正确的流水转账
+			due := map[string]string{orgCounty: "1000", orgPartnership: "5000", orgSPV: "6000"}[tt.owner]
+			result, err = transfer(stub, tt.draftID, tt.next, due, testAccounts[tt.owner], testAccounts[tt.next])
+			if err != nil || !result.Matched {
+				t.Fatalf("transfer after mismatch: %+v %v", result, err)
+			}
+			if draft := queryDraft(t, stub, tt.draftID); draft.MismatchCode != "" || draft.MismatchReason != "" {
+				t.Fatalf("mismatch is not cleared: %+v", draft)
+			}
+		})
+	}
+}
+
+func TestTransferPartialPayment(t *testing.T) {
+	stub := newTestGroup(t)
+	mustTransfer(t, stub, draftProvince, orgProvince, orgPartnership, "2000")
+	mustTransfer(t, stub, draftICBC, orgICBC, orgPartnership, "3000")
+
+	payments := []struct {
+		sum string
+		wantStatus string
+		wantOwner string
+		wantPaid map[string]string 	//每张汇票本节点已付金额，付清后清零
+	}{
+		{"1500", statusPartiallyPaid, orgPartnership, map[string]string{draftProvince: "1500", draftICBC: "0"}},
+		{"2500", statusPartiallyPaid, orgPartnership, map[string]string{draftProvince: "2000", draftICBC: "2000"}},
+		{"1000", statusInTransit, orgSPV, map[string]string{draftProvince: "0", draftICBC: "0"}},
+	}
+	for i, p := range payments {
+		result, err := transfer(stub, draftProvince, orgSPV, p.sum, testAccounts[orgPartnership], testAccounts[orgSPV])
+		if err != nil {
+			t.Fatalf("payment %d: %v", i, err)
+		}
+		if !result.Matched || result.Status != p.wantStatus || result.NewOwner != p.wantOwner || result.Partial != (p.wantStatus == statusPartiallyPaid) {
+			t.Fatalf("payment %d: result %+v", i, result)
+		}
+		for draftID, paid := range p.wantPaid {
+			draft := queryDraft(t, stub, draftID)
+			if draft.Status != p.wantStatus || draft.Owner != p.wantOwner || !draft.Paid.Equal(mustParse(paid)) {
+				t.Errorf("payment %d: draft %s status %s owner %s paid %s, want paid %s", i, draftID, draft.Status, draft.Owner, draft.Paid, paid)
+			}
+		}
+	}
+}
+
+func TestTransferErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		setup func(*testing.T, *mockstub.MockStub, *mockstub.OrgRegistry)
+		operator string
+		to string 	//变更后的所属机构，默认为SPV
+		sum string
+		wantCode string
+	}{
+		{name: "invalid sum", sum: "1,000", wantCode: errInvalidSum},
+		{name: "operator is not the owner's bank", operator: orgCounty},
+		{name: "cancelled draft", setup: func(t *testing.T, stub *mockstub.MockStub, registry *mockstub.OrgRegistry) {
+			mustInvoke(t, stub, "cancel", orgCounty, draftCounty)
+		}, wantCode: errInvalidState},
+		{name: "new owner is not the next step of the route", to: orgProvince, wantCode: errInvalidOwner},
+		{name: "new owner is not registered", to: "10299", wantCode: errInvalidOwner},
+		{name: "new owner is deactivated", setup: func(t *testing.T, stub *mockstub.MockStub, registry *mockstub.OrgRegistry) {
+			registry.Org(orgSPV).Active = false
+		}, wantCode: errInvalidOwner},
+		{name: "receipt account does not belong to the new owner", setup: func(t *testing.T, stub *mockstub.MockStub, registry *mockstub.OrgRegistry) {
+			registry.Org(orgSPV).Accounts = []string{"6222000019999"}
+		}, wantCode: errInvalidOwner},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := loadTestRegistry(t)
+			stub := issueTestGroup(t, newTestStubWithRegistry(t, registry))
+			if tt.setup != nil {
+				tt.setup(t, stub, registry)
+			}
+			operator := tt.operator
+			if operator == "" {
+				operator = orgBank
+			}
+			to := tt.to
+			if to == "" {
+				to = orgSPV
+			}
+			sum := tt.sum
+			if sum == "" {
+				sum = "1000"
+			}
+			before := queryDraft(t, stub, draftCounty)
+
+			_, err := invoke(stub, "transfer", operator, draftCounty, to, sum, testAccounts[orgCounty], testAccounts[orgSPV], "")
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if code := errorCode(err); code != tt.wantCode {
+				t.Fatalf("error code = %q, want %q (%v)", code, tt.wantCode, err)
+			}
+			if after := queryDraft(t, stub, draftCounty); !reflect.DeepEqual(after, before) {
+				t.Fatalf("the draft changed: %+v", after)
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	tests := []struct {
+		name string
+		mismatch bool 	//先以错误的收款账户转账，使汇票处于不匹配状态
+		pending bool 	//已有一个手工平账申请
+		operator string
+		newOwner string 	//变更后的所属机构，默认为SPV
+		reason string
+		note string
+		wantCode string
+		wantErr bool
+	}{
+		{name: "request", mismatch: true},
+		{name: "another reason code", mismatch: true, reason: "SPLIT_PAYMENT"},
+		{name: "draft is not mismatched", wantErr: true, wantCode: errInvalidState},
+		{name: "unknown reason code", mismatch: true, reason: "LOST", wantErr: true, wantCode: errInvalidReason},
+		{name: "empty note", mismatch: true, note: " ", wantErr: true, wantCode: errInvalidReason},
+		{name: "operator is not the owner's bank", mismatch: true, operator: orgCounty, wantErr: true},
+		{name: "pending request", mismatch: true, pending: true, wantErr: true, wantCode: errInvalidState},
+		{name: "new owner is not the next step of the route", mismatch: true, newOwner: orgProjectCompany, wantErr: true, wantCode: errInvalidOwner},
+		{name: "new owner is not registered", mismatch: true, newOwner: "10299", wantErr: true, wantCode: errInvalidOwner},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := newTestGroup(t)
+			if tt.mismatch {
+				_, err := transfer(stub, draftCounty, orgSPV, "1000", testAccounts[orgCounty], "wrong")
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.pending {
+				mustInvoke(t, stub, "update", orgBank, draftCounty, orgSPV, "OTHER", "first request")
+			}
+			operator, newOwner, reason, note := tt.operator, tt.newOwner, tt.reason, tt.note
+			if operator == "" {
+				operator = orgBank
+			}
+			if newOwner == "" {
+				newOwner = orgSPV
+			}
+			if reason == "" {
+				reason = "BANK_FLOW_ERROR"
+			}
+			if note == "" {
+				note = "receipt account typo in the bank flow"
+			}
+			before := queryDraft(t, stub, draftCounty)
+
+			_, err := invoke(stub, "update", operator, draftCounty, newOwner, reason, note)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				if code := errorCode(err); code != tt.wantCode {
+					t.Fatalf("error code = %q, want %q (%v)", code, tt.wantCode, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			//申请不改变汇票，复核通过后才生效
+			if after := queryDraft(t, stub, draftCounty); !reflect.DeepEqual(after, before) {
+				t.Fatalf("the draft changed before approval: %+v", after)
+			}
+			event := lastEvent(t, stub)
+			if event.Type != eventDraftReconcileRequested || event.Reason != reason || event.PrevOwner != orgCounty || event.NewOwner != orgSPV {
+				t.Fatalf("event %+v", event)
+			}
+		})
+	}
+}
+
+func TestApproveUpdate(t *testing.T) {
+	tests := []struct {
+		name string
+		approver string
+		decision string
+		deactivate string 	//申请后停用的机构
+		wantErr bool
+		wantStatus string
+		wantOwner string
+	}{
+		{name: "approve", approver: orgCounty, decision: "approve", wantStatus: statusReconciled, wantOwner: orgSPV},
+		{name: "reject", approver: orgCounty, decision: "reject", wantStatus: statusMismatch, wantOwner: orgCounty},
+		{name: "requester can not approve", approver: orgBank, decision: "approve", wantErr: true},
+		{name: "only the owner can approve", approver: orgSPV, decision: "approve", wantErr: true},
+		{name: "unknown decision", approver: orgCounty, decision: "maybe", wantErr: true},
+		{name: "new owner deactivated after the request", approver: orgCounty, decision: "approve", deactivate: orgSPV, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := loadTestRegistry(t)
+			stub := issueTestGroup(t, newTestStubWithRegistry(t, registry))
+			_, err := transfer(stub, draftCounty, orgSPV, "1000", testAccounts[orgCounty], "wrong")
+			if err != nil {
+				t.Fatal(err)
+			}
+			mustInvoke(t, stub, "update", orgBank, draftCounty, orgSPV, "BANK_FLOW_ERROR", "receipt account typo")
+			if tt.deactivate != "" {
+				registry.Org(tt.deactivate).Active = false
+			}
+
+			_, err = invoke(stub, "approveUpdate", tt.approver, draftCounty, tt.decision)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			draft := queryDraft(t, stub, draftCounty)
+			if draft.Status != tt.wantStatus || draft.Owner != tt.wantOwner {
+				t.Fatalf("status %s owner %s", draft.Status, draft.Owner)
+			}
+			//申请只能处理一次
+			if _, err := invoke(stub, "approveUpdate", tt.approver, draftCounty, tt.decision); err == nil {
+				t.Fatal("the request is approved twice")
+			}
+			if tt.decision == "reject" {
+				//驳回后可以重新申请
+				mustInvoke(t, stub, "update", orgBank, draftCounty, orgSPV, "OTHER", "second request")
+				return
+			}
+
+			node := draft.TruePath[len(draft.TruePath)-1]
+			want := InfoStruct{Account: testAccounts[orgCounty], Time: "20170301", Source: pathSourceManual, ReasonCode: "BANK_FLOW_ERROR", Note: "receipt account typo", Requester: orgBank, Approver: orgCounty}
+			if node != want {
+				t.Fatalf("true path node %+v, want %+v", node, want)
+			}
+			if draft.MismatchCode != "" || draft.MismatchReason != "" {
+				t.Fatalf("mismatch is not cleared: %s %s", draft.MismatchCode, draft.MismatchReason)
+			}
+			event := lastEvent(t, stub)
+			if event.Type != eventDraftReconciled || event.PrevOwner != orgCounty || event.NewOwner != orgSPV {
+				t.Fatalf("event %+v", event)
+			}
+		})
+	}
+}
+
+//汇票的历史，按时间排序
+func queryHistory(t *testing.T, stub *mockstub.MockStub, draftID string) []historyStruct {
+	var histories []historyStruct
+
+	b, err := stub.MockQuery("queryHistory", []string{draftID})
+	if err != nil {
+		t.Fatalf("queryHistory %s: %v", draftID, err)
+	}
+	err = json.Unmarshal(b, &histories)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return histories
+}
+
+//SPV一次转出整个汇票组后冲正：项目公司申请，SPV同意，三张汇票一起恢复为SPV所有
+func TestReverseTransfer(t *testing.T) {
+	stub := newTestGroup(t)
+	for _, s := range toSPV {
+		mustTransfer(t, stub, s.DraftID, s.From, s.To, s.Sum)
+	}
+	mustTransfer(t, stub, draftProvince, orgSPV, orgProjectCompany, "6000")
+	histories := queryHistory(t, stub, draftProvince)
+	transferTxID := histories[len(histories)-1].TxID
+	wantDrafts := []string{draftProvince, draftCounty, draftICBC}
+
+	//第一个机构同意时只记录申请，汇票不变
+	before := queryDraft(t, stub, draftProvince)
+	mustInvoke(t, stub, "reverseTransfer", orgProjectCompany, draftProvince, "paid to the wrong project")
+	if after := queryDraft(t, stub, draftProvince); !reflect.DeepEqual(after, before) {
+		t.Fatalf("the draft changed before the other owner agreed: %+v", after)
+	}
+	event := lastEvent(t, stub)
+	if event.Type != eventDraftReverseRequested || event.PrevOwner != orgProjectCompany || event.NewOwner != orgSPV || !reflect.DeepEqual(event.Drafts, wantDrafts) {
+		t.Fatalf("event %+v", event)
+	}
+	//申请人不能自己同意
+	if _, err := invoke(stub, "reverseTransfer", orgProjectCompany, draftProvince, "paid to the wrong project"); err == nil {
+		t.Fatal("the requester approved its own reversal")
+	}
+
+	mustInvoke(t, stub, "reverseTransfer", orgSPV, draftProvince, "agreed")
+	for _, draftID := range wantDrafts {
+		draft := queryDraft(t, stub, draftID)
+		if draft.Owner != orgSPV || draft.Status != statusInTransit {
+			t.Errorf("draft %s owner %s status %s", draftID, draft.Owner, draft.Status)
+		}
+		//追加冲正节点，被冲正的节点保留
+		node := draft.TruePath[len(draft.TruePath)-1]
+		want := InfoStruct{Account: testAccounts[orgSPV], Time: "20170111", Source: pathSourceReversal, Note: "paid to the wrong project", Requester: orgProjectCompany, Approver: orgSPV, ReversedTxID: transferTxID}
+		if node != want || draft.TruePath[len(draft.TruePath)-2].Source != "" {
+			t.Errorf("draft %s true path %+v, want last node %+v", draftID, draft.TruePath, want)
+		}
+		histories := queryHistory(t, stub, draftID)
+		last := histories[len(histories)-1]
+		if last.Action != "reverse" || last.Operator != orgSPV || last.PrevOwner != orgProjectCompany || last.NewOwner != orgSPV || last.Status != statusInTransit {
+			t.Errorf("draft %s last history %+v", draftID, last)
+		}
+	}
+	if group := queryGroup(t, stub, "12345678"); group.Stage != orgSPV {
+		t.Errorf("group stage = %s, want %s", group.Stage, orgSPV)
+	}
+	event = lastEvent(t, stub)
+	if event.Type != eventDraftReversed || event.PrevOwner != orgProjectCompany || event.NewOwner != orgSPV || event.Reason != "paid to the wrong project" || !reflect.DeepEqual(event.Drafts, wantDrafts) {
+		t.Fatalf("event %+v", event)
+	}
+
+	//冲正不能再被冲正，SPV可以重新转出
+	_, err := invoke(stub, "reverseTransfer", orgSPV, draftProvince, "again")
+	if code := errorCode(err); code != errInvalidState {
+		t.Fatalf("reversing a reversal: error code = %q (%v)", code, err)
+	}
+	mustTransfer(t, stub, draftProvince, orgSPV, orgProjectCompany, "6000")
+}
+
+func TestReverseTransferErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		setup func(*testing.T, *mockstub.MockStub)
+		operator string
+		reason string
+		wantCode string
+	}{
+		{name: "not transferred", operator: orgProvince, wantCode: errInvalidState},
+		{name: "empty reason", setup: func(t *testing.T, stub *mockstub.MockStub) {
+			mustTransfer(t, stub, draftProvince, orgProvince, orgPartnership, "2000")
+		}, operator: orgProvince, reason: " ", wantCode: errInvalidReason},
+		{name: "operator is neither owner", setup: func(t *testing.T, stub *mockstub.MockStub) {
+			mustTransfer(t, stub, draftProvince, orgProvince, orgPartnership, "2000")
+		}, operator: orgICBC},
+		{name: "requester also approves", setup: func(t *testing.T, stub *mockstub.MockStub) {
+			mustTransfer(t, stub, draftProvince, orgProvince, orgPartnership, "2000")
+			mustInvoke(t, stub, "reverseTransfer", orgProvince, draftProvince, "wrong account")
+		}, operator: orgProvince},
+		//申请后有限合伙又转出了汇票，省的转账不再是最近一次转账，不能冲正
+		{name: "after a later transfer", setup: func(t *testing.T, stub *mockstub.MockStub) {
+			mustTransfer(t, stub, draftProvince, orgProvince, orgPartnership, "2000")
+			mustTransfer(t, stub, draftICBC, orgICBC, orgPartnership, "3000")
+			mustInvoke(t, stub, "reverseTransfer", orgProvince, draftProvince, "wrong account")
+			mustTransfer(t, stub, draftProvince, orgPartnership, orgSPV, "5000")
+		}, operator: orgProvince},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := newTestGroup(t)
+			if tt.setup != nil {
+				tt.setup(t, stub)
+			}
+			reason := tt.reason
+			if reason == "" {
+				reason = "wrong account"
+			}
+			before := queryDraft(t, stub, draftProvince)
+
+			_, err := invoke(stub, "reverseTransfer", tt.operator, draftProvince, reason)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if code := errorCode(err); code != tt.wantCode {
+				t.Fatalf("error code = %q, want %q (%v)", code, tt.wantCode, err)
+			}
+			if after := queryDraft(t, stub, draftProvince); !reflect.DeepEqual(after, before) {
+				t.Fatalf("the draft changed: %+v", after)
+			}
+		})
+	}
+}
+
+func TestAdminFunctions(t *testing.T) {
+	tests := []struct {
+		name string
+		function string
+		operator string
+		twice bool 	//管理员先设置一次
+		wantErr bool
+	}{
+		{name: "deployer", function: "setOrgRegistry", operator: orgICBC},
+		{name: "another organization", function: "setOrgRegistry", operator: orgSPV, wantErr: true},
+		{name: "only once", function: "setOrgRegistry", operator: orgICBC, twice: true, wantErr: true},
+		{name: "project chaincode by deployer", function: "setProjectChaincode", operator: orgICBC},
+		{name: "project chaincode by another organization", function: "setProjectChaincode", operator: orgSPV, wantErr: true},
+		{name: "project chaincode only once", function: "setProjectChaincode", operator: orgICBC, twice: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//机构注册链码在部署后设置，此时还不能通过它校验操作人
+			stub := deployTestStub(t, loadTestRegistry(t))
+			if tt.function != "setOrgRegistry" {
+				mustInvoke(t, stub, "setOrgRegistry", orgICBC, "zzjg")
+			}
+			if tt.twice {
+				mustInvoke(t, stub, tt.function, orgICBC, "xm")
+			}
+
+			_, err := invoke(stub, tt.function, tt.operator, "evil")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+//根据交易证书确定调用者：证书属性orgID只在MSP与机构注册的一致时采信，没有属性的证书按testdata/certs中证书的指纹确定
+func TestCaller(t *testing.T) {
+	tests := []struct {
+		name string
+		caller string 	//SetCaller设置的证书属性orgID，为空时使用certFile
+		mspID string 	//不为空时修改调用者的MSP
+		certFile string 	//testdata/certs下的证书，没有属性
+		wantErr bool
+	}{
+		{name: "attribute", caller: orgICBC},
+		{name: "attribute from another MSP", caller: orgICBC, mspID: orgCounty + "MSP", wantErr: true},
+		{name: "certificate", certFile: "20006.pem"},
+		{name: "certificate of another organization", certFile: "10101.pem", wantErr: true},
+		{name: "unregistered certificate", certFile: "admin.pem", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := newTestStub(t)
+			if tt.caller != "" {
+				stub.SetCaller(tt.caller)
+			}else {
+				cert, err := ioutil.ReadFile("../../testdata/certs/" + tt.certFile)
+				if err != nil {
+					t.Fatal(err)
+				}
+				stub.SetCallerCertificate(cert)
+			}
+			if tt.mspID != "" {
+				stub.MSPID = tt.mspID
+			}
+
+			b, _ := json.Marshal(testDraftInfo(draftICBC))
+			_, err := stub.MockInvoke("create", []string{draftICBC, string(b), orgICBC})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+//停用的机构不能再操作，没有配置角色的函数也一样
+func TestInactiveOperator(t *testing.T) {
+	registry := loadTestRegistry(t)
+	stub := issueTestGroup(t, newTestStubWithRegistry(t, registry))
+	registry.Org(orgBank).Active = false
+
+	_, err := transfer(stub, draftCounty, orgSPV, "1000", testAccounts[orgCounty], testAccounts[orgSPV])
+	if err == nil || !strings.Contains(err.Error(), "is not active") {
+		t.Fatalf("err = %v, want the operator to be inactive", err)
+	}
+	if draft := queryDraft(t, stub, draftCounty); draft.Owner != orgCounty {
+		t.Fatalf("owner = %s, want %s", draft.Owner, orgCounty)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	tests := []struct {
+		name string
+		setup func(*testing.T, *mockstub.MockStub)
+		operator string
+		wantErr bool
+		wantCode string
+	}{
+		{name: "issued"},
+		{name: "operator is not the initiator", operator: orgProvince, wantErr: true},
+		{name: "transferred", setup: func(t *testing.T, stub *mockstub.MockStub) {
+			mustTransfer(t, stub, draftCounty, orgCounty, orgSPV, "1000")
+		}, wantErr: true, wantCode: errInvalidState},
+		{name: "transferred and reversed", setup: func(t *testing.T, stub *mockstub.MockStub) {
+			mustTransfer(t, stub, draftCounty, orgCounty, orgSPV, "1000")
+			mustInvoke(t, stub, "reverseTransfer", orgCounty, draftCounty, "wrong account")
+			mustInvoke(t, stub, "reverseTransfer", orgSPV, draftCounty, "wrong account")
+			if draft := queryDraft(t, stub, draftCounty); draft.Status != statusIssued {
+				t.Fatalf("status after reversal = %s", draft.Status)
+			}
+		}, wantErr: true, wantCode: errInvalidState},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := newTestGroup(t)
+			if tt.setup != nil {
+				tt.setup(t, stub)
+			}
+			operator := tt.operator
+			if operator == "" {
+				operator = orgCounty
+			}
+
+			_, err := invoke(stub, "cancel", operator, draftCounty)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if code := errorCode(err); code != tt.wantCode {
+				t.Fatalf("error code = %q, want %q (%v)", code, tt.wantCode, err)
+			}
+			if draft := queryDraft(t, stub, draftCounty); (draft.Status == statusCancelled) == tt.wantErr {
+				t.Fatalf("status = %s", draft.Status)
+			}
+		})
+	}
+}
+
+//通过queryGroup查询汇票组
+func queryGroup(t *testing.T, stub *mockstub.MockStub, groupID string) draftGroupStruct {
+	var result groupQueryResult
+
+	b, err := stub.MockQuery("queryGroup", []string{groupID})
+	if err != nil {
+		t.Fatalf("queryGroup %s: %v", groupID, err)
+	}
+	err = json.Unmarshal(b, &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result.Group
+}
+
+//组内一张汇票作废后，其他汇票照常转账，作废的汇票不再涉及，金额也不计入
+func TestCancelInGroup(t *testing.T) {
+	stub := newTestGroup(t)
+	mustInvoke(t, stub, "cancel", orgCounty, draftCounty)
+	if group := queryGroup(t, stub, "12345678"); !group.Total.Equal(mustParse("5000")) {
+		t.Fatalf("group total = %s, want 5000", group.Total)
+	}
+
+	mustTransfer(t, stub, draftProvince, orgProvince, orgPartnership, "2000")
+	mustTransfer(t, stub, draftICBC, orgICBC, orgPartnership, "3000")
+	mustTransfer(t, stub, draftProvince, orgPartnership, orgSPV, "5000")
+	//作废的汇票仍属于县，汇票组的阶段只看未作废的汇票
+	if group := queryGroup(t, stub, "12345678"); group.Stage != orgSPV {
+		t.Fatalf("group stage = %s, want %s", group.Stage, orgSPV)
+	}
+
+	result, err := transfer(stub, draftICBC, orgProjectCompany, "5000", testAccounts[orgSPV], testAccounts[orgProjectCompany])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Matched || result.Partial || !reflect.DeepEqual(result.Drafts, []string{draftICBC, draftProvince}) {
+		t.Fatalf("result %+v", result)
+	}
+	if draft := queryDraft(t, stub, draftCounty); draft.Status != statusCancelled || draft.Owner != orgCounty || len(draft.TruePath) != 0 {
+		t.Fatalf("cancelled draft changed: status %s owner %s", draft.Status, draft.Owner)
+	}
+}
+
+//每个发行机构只把自己发行的过期汇票标记为过期
+func TestExpireOverdue(t *testing.T) {
+	stub := newTestGroup(t)
+	stub.Time = time.Date(2017, 3, 21, 2, 0, 0, 0, time.UTC)
+
+	var results []expireResult
+	err := json.Unmarshal(mustInvoke(t, stub, "expireOverdue", orgCounty), &results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(results, []expireResult{{DraftID: draftCounty}}) {
+		t.Fatalf("results = %+v, want [%s]", results, draftCounty)
+	}
+	for draftID, want := range map[string]string{draftCounty: statusExpired, draftProvince: statusIssued, draftICBC: statusIssued} {
+		if draft := queryDraft(t, stub, draftID); draft.Status != want {
+			t.Errorf("draft %s status = %s, want %s", draftID, draft.Status, want)
+		}
+	}
+	//组内汇票都过期后总金额为0
+	mustInvoke(t, stub, "expireOverdue", orgProvince)
+	mustInvoke(t, stub, "expireOverdue", orgICBC)
+	if group := queryGroup(t, stub, "12345678"); group.Total.Minor != 0 {
+		t.Errorf("group total = %s, want 0", group.Total)
+	}
+}
+
+//有关联项目的汇票过期时标记项目资金进度，标记失败时汇票仍然过期，失败原因在结果中返回
+func TestExpireOverdueProject(t *testing.T) {
+	for _, deleted := range []bool{false, true} {
+		stub := newTestStub(t)
+		projects, projectStub := setTestProjects(t, stub)
+		draftInfo := testDraftInfo(draftCounty)
+		draftInfo.ProjectID = "P001"
+		b, _ := json.Marshal(draftInfo)
+		mustInvoke(t, stub, "create", orgCounty, draftCounty, string(b))
+		//项目在汇票发行后被删除，项目链码拒绝标记
+		if deleted {
+			delete(projects.Projects, "P001")
+		}
+		stub.Time = time.Date(2017, 3, 21, 2, 0, 0, 0, time.UTC)
+
+		var results []expireResult
+		err := json.Unmarshal(mustInvoke(t, stub, "expireOverdue", orgCounty), &results)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].DraftID != draftCounty || results[0].ProjectID != "P001" || (results[0].FlagError != "") != deleted {
+			t.Fatalf("deleted %v: results = %+v", deleted, results)
+		}
+		if draft := queryDraft(t, stub, draftCounty); draft.Status != statusExpired {
+			t.Fatalf("deleted %v: status = %s", deleted, draft.Status)
+		}
+		if flagged := projectStub.State[draftCounty] != nil; flagged == deleted {
+			t.Fatalf("deleted %v: fund progress flagged = %v", deleted, flagged)
+		}
+	}
+}
+
+//queryByState是queryByStatus原来的名称
+func TestQueryByState(t *testing.T) {
+	stub := newTestGroup(t)
+	mustInvoke(t, stub, "cancel", orgCounty, draftCounty)
+
+	for _, status := range []string{statusIssued, statusCancelled} {
+		want, err := stub.MockQuery("queryByStatus", []string{status})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := stub.MockQuery("queryByState", []string{status})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("queryByState %s = %s, want %s", status, got, want)
+		}
+	}
+}
+
+//按索引分页查询，Bookmark由fabric返回，最后一页为空
+func TestQueryByIndexPages(t *testing.T) {
+	stub := newTestGroup(t)
+
+	query := func(function string, args ...string) (draftPage, error) {
+		var page draftPage
+		b, err := stub.MockQuery(function, args)
+		if err != nil {
+			return page, err
+		}
+		err = json.Unmarshal(b, &page)
+		return page, err
+	}
+	ids := func(page draftPage) []string {
+		var ids []string
+		for _, draft := range page.Drafts {
+			ids = append(ids, draft.DraftID)
+		}
+		return ids
+	}
+
+	first, err := query("queryByStatus", statusIssued, "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids(first), []string{draftCounty, draftProvince}) || first.Bookmark == "" {
+		t.Fatalf("first page %v bookmark %q", ids(first), first.Bookmark)
+	}
+	second, err := query("queryByStatus", statusIssued, "2", first.Bookmark)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids(second), []string{draftICBC}) || second.Bookmark != "" {
+		t.Fatalf("second page %v bookmark %q", ids(second), second.Bookmark)
+	}
+	all, err := query("queryByOwner", orgCounty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids(all), []string{draftCounty}) || all.Bookmark != "" {
+		t.Fatalf("owner page %v bookmark %q", ids(all), all.Bookmark)
+	}
+
+	//其他查询的书签、不合法的每页数量都返回错误
+	for _, args := range [][]string{{orgCounty, "2", first.Bookmark}, {orgCounty, "0"}, {orgCounty, "101"}} {
+		if _, err := query("queryByOwner", args...); err == nil {
+			t.Errorf("queryByOwner %q: expected an error", args)
+		}
+	}
+}
+
+func TestReconcileBatch(t *testing.T) {
+	province := bankEntryStruct{BankTime: "2017-03-01 10:00:00", PayAccount: testAccounts[orgProvince], ReceiptAccount: testAccounts[orgPartnership], Amount: "2000"}
+	provincePart := province
+	provincePart.Amount = "1000"
+	unknown := province
+	unknown.PayAccount = "6222000099999"
+
+	tests := []struct {
+		name string
+		entries []bankEntryStruct
+		wantResults []string 	//每一行的结果
+		wantPaid string 	//123456782本节点已付金额
+		wantOwner string
+		wantPayments int 	//123456782的部分付款历史条数
+	}{
+		{name: "paid in full", entries: []bankEntryStruct{province}, wantResults: []string{batchMatched}, wantPaid: "0", wantOwner: orgPartnership},
+		{name: "partial payment", entries: []bankEntryStruct{provincePart}, wantResults: []string{batchMatched}, wantPaid: "1000", wantOwner: orgProvince, wantPayments: 1},
+		{name: "unknown account", entries: []bankEntryStruct{unknown}, wantResults: []string{batchUnmatched}, wantPaid: "0", wantOwner: orgProvince},
+		{name: "same draft twice", entries: []bankEntryStruct{provincePart, provincePart}, wantResults: []string{batchMatched, batchFailed}, wantPaid: "1000", wantOwner: orgProvince, wantPayments: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var report batchReport
+			var histories []historyStruct
+
+			stub := newTestGroup(t)
+			b, err := json.Marshal(tt.entries)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = json.Unmarshal(mustInvoke(t, stub, "reconcileBatch", orgBank, "json", string(b)), &report)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(report.Lines) != len(tt.wantResults) {
+				t.Fatalf("report %+v", report)
+			}
+			for i, want := range tt.wantResults {
+				if report.Lines[i].Result != want || report.Lines[i].Line != i + 1 {
+					t.Errorf("line %d: %+v, want %s", i + 1, report.Lines[i], want)
+				}
+			}
+
+			draft := queryDraft(t, stub, draftProvince)
+			if draft.Owner != tt.wantOwner || !draft.Paid.Equal(mustParse(tt.wantPaid)) {
+				t.Errorf("draft owner %s paid %s, want %s %s", draft.Owner, draft.Paid, tt.wantOwner, tt.wantPaid)
+			}
+			b, err = stub.MockQuery("queryHistory", []string{draftProvince})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = json.Unmarshal(b, &histories)
+			if err != nil {
+				t.Fatal(err)
+			}
+			payments := 0
+			for _, history := range histories {
+				if history.Action == "payment" {
+					payments++
+				}
+			}
+			if payments != tt.wantPayments {
+				t.Errorf("%d payment histories, want %d: %+v", payments, tt.wantPayments, histories)
+			}
+		})
+	}
+}
+
+//csv流水第一行为表头，行号为文件中的行号；同一汇票组的不同汇票可以在一个批次中转账，同一张汇票只能变更一次
+func TestReconcileBatchCSV(t *testing.T) {
+	var report batchReport
+	var histories []historyStruct
+
+	stub := newTestGroup(t)
+	data := "转账时间,付款账户,收款账户,金额\n" +
+		"2017-03-01 10:00:00," + testAccounts[orgProvince] + "," + testAccounts[orgPartnership] + ",2000\n" +
+		"2017-03-01 10:00:00," + testAccounts[orgICBC] + "," + testAccounts[orgPartnership] + ",3000\n" +
+		"2017-03-01 10:00:00," + testAccounts[orgProvince] + "," + testAccounts[orgPartnership] + ",2000\n"
+	err := json.Unmarshal(mustInvoke(t, stub, "reconcileBatch", orgBank, "csv", data), &report)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []batchLineResult{
+		{Line: 2, Result: batchMatched, DraftID: draftProvince},
+		{Line: 3, Result: batchMatched, DraftID: draftICBC},
+		{Line: 4, Result: batchFailed, DraftID: draftProvince},
+	}
+	if len(report.Lines) != len(want) || report.Matched != 2 || report.Failed != 1 {
+		t.Fatalf("report %+v", report)
+	}
+	for i, w := range want {
+		got := report.Lines[i]
+		if got.Line != w.Line || got.Result != w.Result || got.DraftID != w.DraftID {
+			t.Errorf("line %d: %+v, want %+v", i, got, w)
+		}
+	}
+	if !strings.Contains(report.Lines[2].Message, "line 2") {
+		t.Errorf("line 4 message = %q", report.Lines[2].Message)
+	}
+
+	//两行都更新了汇票组，汇票组只写入一次，不能丢失其中一行的变更
+	group := queryGroup(t, stub, "12345678")
+	for _, member := range group.Members {
+		wantOwner := map[string]string{draftCounty: orgCounty, draftProvince: orgPartnership, draftICBC: orgPartnership}[member.DraftID]
+		if member.Owner != wantOwner {
+			t.Errorf("group member %s owner = %s, want %s", member.DraftID, member.Owner, wantOwner)
+		}
+	}
+	b, err := stub.MockQuery("queryHistory", []string{draftProvince})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = json.Unmarshal(b, &histories)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(histories) != 2 || histories[1].Action != "transfer" {
+		t.Fatalf("histories %+v", histories)
+	}
+
+	//有限合伙可以继续转出两张汇票
+	mustTransfer(t, stub, draftProvince, orgPartnership, orgSPV, "5000")
+}
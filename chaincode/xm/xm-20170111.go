@@ -0,0 +1,620 @@
+
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+
+//项目
+
+import (
+	"errors"
+	"fmt"
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/xyjxyjxyj/MySC/common"
+	"github.com/xyjxyjxyj/MySC/money"
+)
+
+// SimpleChaincode example simple Chaincode implementation
+type SimpleChaincode struct {
+}
+
+//审核结果结构体 Office表示指挥部办公室 Government表示县政府
+type ApprovalStruct struct {
+	Office string
+	Government string
+}
+
+//数字汇票结构体
+type DraftStruct struct {
+	DraftID string
+	DraftMount money.Money
+	Initiator string `json:",omitempty"`	//汇票发行机构ID，录入资金进度的机构
+	Expired bool `json:",omitempty"`	//汇票已过期，由数字汇票链码的expireOverdue标记
+}
+
+//资金进度结构体
+type FundStruct struct {
+	Priority1 DraftStruct
+	Priority2 DraftStruct
+	Priority3 DraftStruct
+}
+
+
+
+//部署时，传入参数有1个：操作人ID；或者3个：项目ID，项目信息，操作人ID，此时同时创建第一个项目，与原来的部署方式兼容
+//部署者的证书指纹记为管理员，只有管理员可以设置机构注册链码
+//变量名ProjectHash解释，这个里面有个hash，不要理解错了，这是因为原来设计的时候是要存项目信息的hash，而现在的设计是要存项目全信息
+func (t *SimpleChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	return common.Respond(t.deploy(stub, args))
+}
+
+func (t *SimpleChaincode) deploy(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	err := common.CheckArgs(args, 1, 3)
+	if err != nil {
+		return nil, err
+	}
+
+	//部署时还没有设置机构注册链码，不校验权限
+	if len(args) == 3 {
+		_, err := t.createProject(stub, args)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	//记录管理员和操作人
+	return nil, common.RecordInit(stub, args)
+}
+
+//权限表中的函数修改账本，其他函数为查询
+func (t *SimpleChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	return common.Invoke(stub, permissions, t.invoke, t.query)
+}
+
+//修改账本的函数，调用前已经校验过权限，成功后记录操作
+func (t *SimpleChaincode) invoke(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+	if function == "updateApproval" {
+		return t.updateApproval(stub, args)
+	}else if function == "createProject"{
+		return t.createProject(stub,args)
+	}else if function == "updateProject"{
+		return t.updateProject(stub,args)
+	}else if function == "updateProjectProgress"{
+		return t.updateProjectProgress(stub,args)
+	}else if function == "updateFundProgress"{
+		return t.updateFundProgress(stub,args)
+	}else if function == "expireFundProgress"{
+		return t.expireFundProgress(stub,args)
+	}else if function == "setOrgRegistry"{
+		return common.SetOrgRegistry(stub,args)
+	}
+
+	return nil, errors.New("no such a method on this chaincode")
+}
+
+//所有项目数据都以项目ID为组合键的属性存储，一个链码可以管理多个项目
+//组合键的类型
+const (
+	keyProject = "Project"	//项目信息
+	keyApprovalResult = "ApprovalResult"	//审核结果
+	keyProjectProgress = "ProjectProgress"	//项目进度
+	keyProjectProgressExplain = "ProjectProgressExplain"	//项目进度说明
+	keyFundProgress = "FundProgress"	//资金进度
+)
+
+//读取项目数据，keyType为组合键的类型，不存在时返回nil
+func getProjectState(stub shim.ChaincodeStubInterface, keyType string, ProjectID string) ([]byte, error) {
+	key, err := common.CreateCompositeKey(stub, keyType, ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	value, err := stub.GetState(key)
+	if err != nil {
+		return nil, errors.New("Failed to get state")
+	}
+	return value, nil
+}
+
+//保存项目数据，keyType为组合键的类型
+func putProjectState(stub shim.ChaincodeStubInterface, keyType string, ProjectID string, value []byte) error {
+	key, err := common.CreateCompositeKey(stub, keyType, ProjectID)
+	if err != nil {
+		return err
+	}
+
+	// Write the state to the ledger
+	return stub.PutState(key, value)
+}
+
+//判断项目是否存在
+func projectExists(stub shim.ChaincodeStubInterface, ProjectID string) (bool, error) {
+	ProjectHash, err := getProjectState(stub, keyProject, ProjectID)
+	if err != nil {
+		return false, err
+	}
+	return ProjectHash != nil, nil
+}
+
+//确认项目存在，所有修改项目数据的函数都要先调用
+func checkProject(stub shim.ChaincodeStubInterface, ProjectID string) error {
+	exists, err := projectExists(stub, ProjectID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.New("The project " + ProjectID + " does not exist")
+	}
+	return nil
+}
+
+//创建项目 传入参数有3个：项目ID，项目信息，操作者编号
+func (t *SimpleChaincode) createProject(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	var ProjectID string	//项目ID
+	var ProjectHash string	//项目信息
+	var err error
+
+	err = common.CheckArgs(args, 3)
+	if err != nil {
+		return nil, err
+	}
+
+	ProjectID = args[0]
+	ProjectHash = args[1]
+
+	//项目ID不能重复
+	exists, err := projectExists(stub, ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, errors.New("The project " + ProjectID + " already exists")
+	}
+
+	// Write the state to the ledger
+	err = putProjectState(stub, keyProject, ProjectID, []byte(ProjectHash))
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+//审核项目 传入参数有4个：项目ID，项目审核进度（审核机构编号（角色为指挥部办公室或县政府），审核结果），操作人编号
+func (t *SimpleChaincode) updateApproval(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	
+	var ProjectID string	//项目ID
+	var OrganizationID string	//审核机构编号
+	var OrganizationResult string 	//该审核机构审核结果
+	var ApprovalResult []byte 	//审核结果
+	var OrganizationRole string 	//审核机构的角色
+	var ResultStruct ApprovalStruct 	//审核结果结构体
+	//var TmpStruct ApprovalStruct 	//将查询结果解析成结构体
+	var TmpResult []byte 	//用于存放查询结果
+	var err error
+
+	err = common.CheckArgs(args, 4)
+	if err != nil {
+		return nil, err
+	}
+
+	// Initialize the chaincode
+	ProjectID = args[0]
+	OrganizationID = args[1]
+	OrganizationResult = args[2]
+
+	//审核机构只能录入自己的审核结果
+	if args[3] != OrganizationID {
+		return nil, errors.New("The operator " + args[3] + " is not the organization " + OrganizationID)
+	}
+
+	err = checkProject(stub, ProjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	//接收查询结果
+	TmpResult, err = getProjectState(stub, keyApprovalResult, ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	//判断审查结果的值，如果为空，说明这是第一次录入结果，给ResultStruct赋空值
+	if TmpResult == nil {
+		ResultStruct.Office = ""
+		ResultStruct.Government = ""
+	}else{
+		//如果不为空，说明之前已经有审查结果了，将之前的值赋给ResultStruct
+		//读不出来时不能当成空结果覆盖
+		err = json.Unmarshal(TmpResult, &ResultStruct)
+		if err != nil {
+			return nil, errors.New("The approval result of project " + ProjectID + " can not be read: " + err.Error())
+		}
+	}
+
+	//通过机构注册链码查询审核机构的角色
+	OrganizationRole, err = common.GetOrgRole(stub, OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	//赋新值
+	if OrganizationRole == common.RoleOffice {
+		ResultStruct.Office = OrganizationResult
+	}else if OrganizationRole == common.RoleCountyGovernment {
+		ResultStruct.Government = OrganizationResult
+	}else {
+		return nil, errors.New("OrganizationID is incorrectly")
+	}
+
+	//将struct转移成json []byte格式
+	ApprovalResult,_ = json.Marshal(ResultStruct)
+
+	// Write the state to the ledger
+	err = putProjectState(stub, keyApprovalResult, ProjectID, ApprovalResult)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+//修改项目 传入参数有3个：项目编号，项目信息，操作者编号
+//项目编号只用于定位项目，不能修改
+func (t *SimpleChaincode) updateProject(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	var ProjectID string	//项目ID
+	var NewProjectHash string	//项目信息hash
+	var err error
+
+	err = common.CheckArgs(args, 3)
+	if err != nil {
+		return nil, err
+	}
+
+	// Initialize the chaincode
+	ProjectID = args[0]
+	NewProjectHash = args[1]
+
+	err = checkProject(stub, ProjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Write the state to the ledger
+	err = putProjectState(stub, keyProject, ProjectID, []byte(NewProjectHash))
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+//项目进度 传入参数有4个：项目ID，项目进度（百分数），项目进度说明，操作者编号
+func (t *SimpleChaincode) updateProjectProgress(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	var ProjectID string	//项目ID
+	var ProjectProgress string	//项目进度
+	var ProjectProgressExplain string	//项目进度说明
+	var err error
+
+	err = common.CheckArgs(args, 4)
+	if err != nil {
+		return nil, err
+	}
+
+	// Initialize the chaincode
+	ProjectID = args[0]
+	ProjectProgress = args[1]
+	ProjectProgressExplain = args[2]
+
+	err = checkProject(stub, ProjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Write the state to the ledger
+	err = putProjectState(stub, keyProjectProgress, ProjectID, []byte(ProjectProgress))
+	if err != nil {
+		return nil, err
+	}
+	err = putProjectState(stub, keyProjectProgressExplain, ProjectID, []byte(ProjectProgressExplain))
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+//资金进度 传入参数有5个：项目ID，资金进度（汇票发行机构（县,省,ICBC），数字汇票编号,金额），操作者编号
+func (t *SimpleChaincode) updateFundProgress(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	var ProjectID string	//项目ID
+	var OrganizationID string	//汇票发行机构
+	var DraftID string 	//数字汇票编号
+	var DraftMount money.Money 	//数字汇票金额
+	var FundProgress []byte 	//资金进度
+	var TmpResult []byte 	//用于存放查询结果
+	var ResultStruct FundStruct 	//查询结果结构体 
+	var OrganizationRole string 	//汇票发行机构的角色
+
+	var err error
+
+	err = common.CheckArgs(args, 5)
+	if err != nil {
+		return nil, err
+	}
+
+	// Initialize the chaincode
+	ProjectID = args[0]
+	OrganizationID = args[1]
+	DraftID = args[2]
+	DraftMount, err = money.Parse(args[3])
+	if err != nil {
+		return nil, errors.New("The draft amount " + args[3] + " is not a valid amount")
+	}
+
+	//汇票发行机构只能录入自己发行的汇票
+	if args[4] != OrganizationID {
+		return nil, errors.New("The operator " + args[4] + " is not the organization " + OrganizationID)
+	}
+
+	err = checkProject(stub, ProjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	//接收查询结果
+	TmpResult, err = getProjectState(stub, keyFundProgress, ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	//判断查询结果，如果为空，说明这是第一次录入结果，给ResultStruct赋空值
+	if TmpResult == nil {
+		ResultStruct.Priority1.DraftID = ""
+		ResultStruct.Priority1.DraftMount = money.Money{}
+		ResultStruct.Priority2.DraftID = ""
+		ResultStruct.Priority2.DraftMount = money.Money{}
+		ResultStruct.Priority3.DraftID = ""
+		ResultStruct.Priority3.DraftMount = money.Money{}
+	}else{
+		//如果不为空，说明之前已经有审查结果了，将之前的值赋给ResultStruct
+		//金额等读不出来时不能丢弃后把不完整的资金进度写回去
+		err = json.Unmarshal(TmpResult, &ResultStruct)
+		if err != nil {
+			return nil, errors.New("The fund progress of project " + ProjectID + " can not be read: " + err.Error())
+		}
+	}
+
+	//根据传入参数 赋新值
+	//通过机构注册链码查询汇票发行机构的角色
+	OrganizationRole, err = common.GetOrgRole(stub, OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if OrganizationRole == common.RoleProvince {
+		ResultStruct.Priority2.DraftID = DraftID
+		ResultStruct.Priority2.DraftMount = DraftMount
+		ResultStruct.Priority2.Initiator = OrganizationID
+	}else if OrganizationRole == common.RoleICBC {
+		ResultStruct.Priority3.DraftID = DraftID
+		ResultStruct.Priority3.DraftMount = DraftMount
+		ResultStruct.Priority3.Initiator = OrganizationID
+	}else if OrganizationRole == common.RoleCounty {
+		ResultStruct.Priority1.DraftID = DraftID
+		ResultStruct.Priority1.DraftMount = DraftMount
+		ResultStruct.Priority1.Initiator = OrganizationID
+	}else {
+		return nil, errors.New("OrganizationID is incorrectly") 
+	}
+
+	//将struct转移成json []byte格式
+	FundProgress,_ = json.Marshal(ResultStruct)
+
+	// Write the state to the ledger
+	err = putProjectState(stub, keyFundProgress, ProjectID, []byte(FundProgress))
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+//标记资金进度中的汇票已过期 传入参数有3个：项目ID，数字汇票编号，操作人ID
+//由数字汇票链码的expireOverdue调用；资金进度中没有录入该汇票时不处理
+//项目链码无法确认汇票是否真的过期，只接受录入该汇票的发行机构标记，防止其他机构把别人的汇票标记为过期
+func (t *SimpleChaincode) expireFundProgress(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	var ResultStruct FundStruct 	//资金进度结构体
+
+	err := common.CheckArgs(args, 3)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkProject(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := common.CreateCompositeKey(stub, keyFundProgress, args[0])
+	if err != nil {
+		return nil, err
+	}
+	exists, err := common.GetJSON(stub, key, &ResultStruct)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	found := false
+	for _, draft := range []*DraftStruct{&ResultStruct.Priority1, &ResultStruct.Priority2, &ResultStruct.Priority3} {
+		if draft.DraftID == args[1] {
+			//原来录入时没有记录发行机构，需要发行机构重新录入后才能标记
+			if draft.Initiator != args[2] {
+				return nil, errors.New("The operator " + args[2] + " is not the initiator of draft " + args[1])
+			}
+			draft.Expired = true
+			found = true
+		}
+	}
+	if !found {
+		return nil, nil
+	}
+
+	return nil, common.PutJSON(stub, key, ResultStruct)
+}
+
+//权限表：函数名 → 允许调用的机构角色
+//updateApproval和updateFundProgress的操作人还必须是参数中的机构本身；setOrgRegistry只能由管理员调用一次
+var permissions = map[string][]string{
+	"createProject": {common.RoleProjectCompany, common.RoleOffice},
+	"updateProject": {common.RoleProjectCompany, common.RoleOffice},
+	"updateApproval": {common.RoleOffice, common.RoleCountyGovernment},
+	"updateProjectProgress": {common.RoleProjectCompany, common.RoleOffice},
+	"updateFundProgress": {common.RoleCounty, common.RoleProvince, common.RoleICBC},
+	"expireFundProgress": {common.RoleCounty, common.RoleProvince, common.RoleICBC},
+	"setOrgRegistry": {common.RoleAdmin},
+}
+
+//项目查询结果
+type projectDocument struct {
+	SchemaVersion int 	//查询结果格式版本
+	ProjectID string 	//项目ID
+	Info json.RawMessage 	//项目信息，录入的是json时原样返回，否则作为json字符串返回
+	Progress string `json:",omitempty"`	//项目进度（百分数）
+	ProgressExplain string `json:",omitempty"`	//项目进度说明
+}
+
+//审核结果查询结果
+type approvalDocument struct {
+	SchemaVersion int 	//查询结果格式版本
+	ProjectID string 	//项目ID
+	Office string 	//指挥部办公室审核结果
+	Government string 	//县政府审核结果
+}
+
+//资金进度中的一张汇票
+type fundDraftDocument struct {
+	Priority int 	//顺位，1县，2省，3ICBC
+	DraftID string 	//数字汇票编号
+	Amount money.Money 	//数字汇票金额
+	Expired bool 	//汇票是否已过期
+}
+
+//资金进度查询结果
+type fundProgressDocument struct {
+	SchemaVersion int 	//查询结果格式版本
+	ProjectID string 	//项目ID
+	Drafts []fundDraftDocument 	//已录入的汇票，按顺位排序
+}
+
+//查询，与修改账本的函数一样通过Invoke调用
+//getProject、getApproval、getFundProgress 传入参数都是1个：项目ID
+func (t *SimpleChaincode) query(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+	if len(args) != 1 {
+		return nil, errors.New("Incorrect number of arguments. Expecting project ID to query")
+	}
+
+	if function == "getProject" {
+		return t.getProject(stub, args[0])
+	}else if function == "getApproval" {
+		return t.getApproval(stub, args[0])
+	}else if function == "getFundProgress" {
+		return t.getFundProgress(stub, args[0])
+	}
+
+	return nil, errors.New("Invalid query function name. Expecting \"getProject\", \"getApproval\" or \"getFundProgress\"")
+}
+
+//查询项目信息和项目进度
+func (t *SimpleChaincode) getProject(stub shim.ChaincodeStubInterface, ProjectID string) ([]byte, error) {
+	Info, err := getProjectState(stub, keyProject, ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	if Info == nil {
+		jsonResp := "{\"Error\":\"Nil project for " + ProjectID + "\"}"
+		return nil, errors.New(jsonResp)
+	}
+	Progress, err := getProjectState(stub, keyProjectProgress, ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	ProgressExplain, err := getProjectState(stub, keyProjectProgressExplain, ProjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(projectDocument{SchemaVersion: common.QuerySchemaVersion, ProjectID: ProjectID, Info: common.RawJSON(string(Info)), Progress: string(Progress), ProgressExplain: string(ProgressExplain)})
+}
+
+//查询审核结果，还没有审核时结果为空
+func (t *SimpleChaincode) getApproval(stub shim.ChaincodeStubInterface, ProjectID string) ([]byte, error) {
+	var ResultStruct ApprovalStruct
+
+	err := checkProject(stub, ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	TmpResult, err := getProjectState(stub, keyApprovalResult, ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	if TmpResult != nil {
+		err = json.Unmarshal(TmpResult, &ResultStruct)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(approvalDocument{SchemaVersion: common.QuerySchemaVersion, ProjectID: ProjectID, Office: ResultStruct.Office, Government: ResultStruct.Government})
+}
+
+//查询资金进度，没有录入的顺位不返回
+func (t *SimpleChaincode) getFundProgress(stub shim.ChaincodeStubInterface, ProjectID string) ([]byte, error) {
+	var ResultStruct FundStruct
+
+	err := checkProject(stub, ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	TmpResult, err := getProjectState(stub, keyFundProgress, ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	if TmpResult != nil {
+		err = json.Unmarshal(TmpResult, &ResultStruct)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	document := fundProgressDocument{SchemaVersion: common.QuerySchemaVersion, ProjectID: ProjectID, Drafts: []fundDraftDocument{}}
+	for i, draft := range []DraftStruct{ResultStruct.Priority1, ResultStruct.Priority2, ResultStruct.Priority3} {
+		if draft.DraftID == "" {
+			continue
+		}
+		document.Drafts = append(document.Drafts, fundDraftDocument{Priority: i + 1, DraftID: draft.DraftID, Amount: draft.DraftMount, Expired: draft.Expired})
+	}
+	return json.Marshal(document)
+}
+
+func main() {
+	err := shim.Start(new(SimpleChaincode))
+	if err != nil {
+		fmt.Printf("Error starting Simple chaincode: %s", err)
+	}
+}
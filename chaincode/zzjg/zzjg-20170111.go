@@ -0,0 +1,338 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+
+//组织机构注册
+//数字汇票、项目、募资结构三个链码通过本链码查询机构的角色，不再根据机构ID的前几位判断
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/xyjxyjxyj/MySC/common"
+)
+
+// SimpleChaincode example simple Chaincode implementation
+type SimpleChaincode struct {
+}
+
+//证书指纹到机构ID的索引以指纹为组合键的属性存储
+const keyCertificate = "Certificate"
+
+//银行账户到机构ID的索引以账户（小写）为组合键的属性存储，对账时根据收款账户确定收款机构
+const keyAccount = "Account"
+
+//部署时，传入参数有1个：操作人编号
+//部署者的证书指纹和操作人编号记为管理员，只有管理员可以注册、修改、停用机构
+func (t *SimpleChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	return common.Respond(t.deploy(stub, args))
+}
+
+func (t *SimpleChaincode) deploy(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	err := common.CheckArgs(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	//记录管理员和操作人
+	return nil, common.RecordInit(stub, args)
+}
+
+//权限表中的函数修改账本，其他函数为查询，查询不校验管理员
+func (t *SimpleChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	return common.Invoke(stub, permissions, t.invoke, t.query)
+}
+
+//修改账本的函数，调用前已经校验过管理员，成功后记录操作
+func (t *SimpleChaincode) invoke(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+	if function == "register" {
+		return t.register(stub, args)
+	}else if function == "update" {
+		return t.update(stub, args)
+	}else if function == "deactivate" {
+		return t.deactivate(stub, args)
+	}
+
+	return nil, errors.New("no such a method on this chaincode")
+}
+
+//注册机构 传入参数有3个：机构ID，机构信息（json字符串，其中包含的属性有：角色，所属县ID，银行账户，开户银行机构ID，证书指纹），操作人编号
+func (t *SimpleChaincode) register(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	var orgID string 	//机构ID
+	var orgInfo common.OrgInfo 	//机构信息结构体
+	var orgInfoByte []byte 	//接收机构信息查询结果
+	var err error
+
+	err = common.CheckArgs(args, 3)
+	if err != nil {
+		return nil, err
+	}
+
+	orgID = args[0]
+
+	//机构ID不能重复
+	orgInfoByte, err = stub.GetState(orgID)
+	if err != nil {
+		return nil, errors.New("Failed to get state")
+	}
+	if orgInfoByte != nil {
+		return nil, errors.New("The organization " + orgID + " already exists")
+	}
+
+	orgInfo, err = parseOrgInfo(orgID, args[1])
+	if err != nil {
+		return nil, err
+	}
+	//新注册的机构默认有效
+	orgInfo.Active = true
+
+	return nil, putOrgInfo(stub, orgInfo)
+}
+
+//修改机构 传入参数有3个：机构ID，机构信息（json字符串），操作人编号
+//有效标识不能通过修改变更，停用机构使用deactivate
+func (t *SimpleChaincode) update(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	var orgID string 	//机构ID
+	var orgInfo common.OrgInfo 	//机构信息结构体
+	var oldOrgInfo common.OrgInfo 	//修改前的机构信息
+	var err error
+
+	err = common.CheckArgs(args, 3)
+	if err != nil {
+		return nil, err
+	}
+
+	orgID = args[0]
+
+	oldOrgInfo, err = getOrgInfo(stub, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	orgInfo, err = parseOrgInfo(orgID, args[1])
+	if err != nil {
+		return nil, err
+	}
+	orgInfo.Active = oldOrgInfo.Active
+
+	return nil, putOrgInfo(stub, orgInfo)
+}
+
+//停用机构 传入参数有2个：机构ID，操作人编号
+func (t *SimpleChaincode) deactivate(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	var orgInfo common.OrgInfo 	//机构信息结构体
+	var err error
+
+	err = common.CheckArgs(args, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	orgInfo, err = getOrgInfo(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+	orgInfo.Active = false
+
+	return nil, putOrgInfo(stub, orgInfo)
+}
+
+//将json字符串的机构信息转换成struct并校验角色
+func parseOrgInfo(orgID string, info string) (common.OrgInfo, error) {
+	var orgInfo common.OrgInfo
+
+	err := json.Unmarshal([]byte(info), &orgInfo)
+	if err != nil {
+		return orgInfo, errors.New("The organization information is not valid json: " + err.Error())
+	}
+	orgInfo.OrgID = orgID
+
+	//没有填写角色时按原来的机构ID规则补全角色和所属县
+	if orgInfo.Role == "" {
+		role, county, ok := common.ParseOrgID(orgID)
+		if !ok {
+			return orgInfo, errors.New("The role of organization " + orgID + " is empty")
+		}
+		orgInfo.Role = role
+		if orgInfo.County == "" {
+			orgInfo.County = county
+		}
+	}
+
+	switch orgInfo.Role {
+	case common.RoleCounty, common.RoleSPV, common.RoleCountyGovernment, common.RoleOffice:
+		//县级机构必须填写所属县
+		if orgInfo.County == "" {
+			return orgInfo, errors.New("The county of organization " + orgID + " is empty")
+		}
+	case common.RoleProvince, common.RolePartnership, common.RoleICBC, common.RoleProjectCompany:
+	default:
+		return orgInfo, errors.New("The role " + orgInfo.Role + " is incorrect")
+	}
+	return orgInfo, nil
+}
+
+func getOrgInfo(stub shim.ChaincodeStubInterface, orgID string) (common.OrgInfo, error) {
+	var orgInfo common.OrgInfo
+
+	found, err := common.GetJSON(stub, orgID, &orgInfo)
+	if err != nil {
+		return orgInfo, err
+	}
+	if !found {
+		return orgInfo, errors.New("Entity not found")
+	}
+	return orgInfo, nil
+}
+
+//保存机构信息，同时维护证书指纹索引和银行账户索引，一个证书、一个账户只能属于一个机构
+func putOrgInfo(stub shim.ChaincodeStubInterface, orgInfo common.OrgInfo) error {
+	var oldOrgInfo common.OrgInfo
+
+	_, err := common.GetJSON(stub, orgInfo.OrgID, &oldOrgInfo)
+	if err != nil {
+		return err
+	}
+
+	//删除旧证书的索引
+	//同一交易中读到的是已提交的状态，刚删除的本机构索引仍然可以读到，所以属于本机构的不算重复
+	for _, fingerprint := range oldOrgInfo.Certificates {
+		key, err := common.CreateCompositeKey(stub, keyCertificate, fingerprint)
+		if err != nil {
+			return err
+		}
+		err = stub.DelState(key)
+		if err != nil {
+			return err
+		}
+	}
+	for _, fingerprint := range orgInfo.Certificates {
+		key, err := common.CreateCompositeKey(stub, keyCertificate, fingerprint)
+		if err != nil {
+			return err
+		}
+		ownerID, err := stub.GetState(key)
+		if err != nil {
+			return errors.New("Failed to get state")
+		}
+		if ownerID != nil && string(ownerID) != orgInfo.OrgID {
+			return errors.New("The certificate " + fingerprint + " already belongs to organization " + string(ownerID))
+		}
+		err = stub.PutState(key, []byte(orgInfo.OrgID))
+		if err != nil {
+			return err
+		}
+	}
+
+	//删除旧账户的索引
+	for _, account := range oldOrgInfo.Accounts {
+		key, err := common.CreateCompositeKey(stub, keyAccount, strings.ToLower(account))
+		if err != nil {
+			return err
+		}
+		err = stub.DelState(key)
+		if err != nil {
+			return err
+		}
+	}
+	for _, account := range orgInfo.Accounts {
+		key, err := common.CreateCompositeKey(stub, keyAccount, strings.ToLower(account))
+		if err != nil {
+			return err
+		}
+		ownerID, err := stub.GetState(key)
+		if err != nil {
+			return errors.New("Failed to get state")
+		}
+		if ownerID != nil && string(ownerID) != orgInfo.OrgID {
+			return errors.New("The account " + account + " already belongs to organization " + string(ownerID))
+		}
+		err = stub.PutState(key, []byte(orgInfo.OrgID))
+		if err != nil {
+			return err
+		}
+	}
+
+	return common.PutJSON(stub, orgInfo.OrgID, orgInfo)
+}
+
+//权限表：函数名 → 允许调用的角色，机构注册链码只能由管理员修改
+var permissions = map[string][]string{
+	"register": {common.RoleAdmin},
+	"update": {common.RoleAdmin},
+	"deactivate": {common.RoleAdmin},
+}
+
+//查询，与修改账本的函数一样通过Invoke调用
+//query 传入参数有1个：机构ID，返回机构信息json字符串，其他链码通过InvokeChaincode调用
+//queryByCertificate 传入参数有1个：证书指纹，返回该证书所属机构的信息
+//queryByAccount 传入参数有1个：银行账户，返回该账户所属机构的信息
+func (t *SimpleChaincode) query(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+	if function != "query" && function != "queryByCertificate" && function != "queryByAccount" {
+		return nil, errors.New("Invalid query function name. Expecting \"query\", \"queryByCertificate\" or \"queryByAccount\"")
+	}
+
+	if len(args) != 1 {
+		return nil, errors.New("Incorrect number of arguments. Expecting organization ID, certificate fingerprint or account to query")
+	}
+
+	orgID := args[0]
+	if function == "queryByCertificate" {
+		key, err := common.CreateCompositeKey(stub, keyCertificate, args[0])
+		if err != nil {
+			return nil, err
+		}
+		orgIDByte, err := stub.GetState(key)
+		if err != nil || orgIDByte == nil {
+			jsonResp := "{\"Error\":\"Failed to get organization of certificate " + args[0] + "\"}"
+			return nil, errors.New(jsonResp)
+		}
+		orgID = string(orgIDByte)
+	}else if function == "queryByAccount" {
+		key, err := common.CreateCompositeKey(stub, keyAccount, strings.ToLower(args[0]))
+		if err != nil {
+			return nil, err
+		}
+		orgIDByte, err := stub.GetState(key)
+		if err != nil || orgIDByte == nil {
+			jsonResp := "{\"Error\":\"Failed to get organization of account " + args[0] + "\"}"
+			return nil, errors.New(jsonResp)
+		}
+		orgID = string(orgIDByte)
+	}
+
+	orgInfo, err := getOrgInfo(stub, orgID)
+	if err != nil {
+		jsonResp := "{\"Error\":\"Failed to get organization " + orgID + "\"}"
+		return nil, errors.New(jsonResp)
+	}
+
+	return json.Marshal(orgInfo)
+}
+
+func main() {
+	err := shim.Start(new(SimpleChaincode))
+	if err != nil {
+		fmt.Printf("Error starting Simple chaincode: %s", err)
+	}
+}
@@ -0,0 +1,194 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/xyjxyjxyj/MySC/common"
+	"github.com/xyjxyjxyj/MySC/mockstub"
+)
+
+//部署机构注册链码的管理员
+const admin = "admin"
+
+//由管理员部署机构注册链码，并注册testdata/orgs.json中的县10101
+func newTestStub(t *testing.T) (*mockstub.MockStub, common.OrgInfo) {
+	registry, err := mockstub.LoadOrgRegistry("../../testdata/orgs.json")
+	if err != nil {
+		t.Fatalf("load organizations: %v", err)
+	}
+	county := *registry.Org("10101")
+
+	stub := mockstub.NewMockStub("zzjg", new(SimpleChaincode))
+	stub.SetCaller(admin)
+	_, err = stub.MockInit("init", []string{admin})
+	if err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	mustInvoke(t, stub, "register", county.OrgID, mustMarshal(t, county))
+	return stub, county
+}
+
+//以管理员身份调用，管理员编号作为最后一个参数
+func invoke(stub *mockstub.MockStub, function string, args ...string) ([]byte, error) {
+	stub.SetCaller(admin)
+	return stub.MockInvoke(function, append(args, admin))
+}
+
+func mustInvoke(t *testing.T, stub *mockstub.MockStub, function string, args ...string) []byte {
+	result, err := invoke(stub, function, args...)
+	if err != nil {
+		t.Fatalf("%s %v: %v", function, args, err)
+	}
+	return result
+}
+
+func mustMarshal(t *testing.T, orgInfo common.OrgInfo) string {
+	b, err := json.Marshal(orgInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+//按function查询，返回机构信息，查不到时返回错误
+func query(stub *mockstub.MockStub, function string, key string) (common.OrgInfo, error) {
+	var orgInfo common.OrgInfo
+
+	b, err := stub.MockQuery(function, []string{key})
+	if err != nil {
+		return orgInfo, err
+	}
+	err = json.Unmarshal(b, &orgInfo)
+	return orgInfo, err
+}
+
+func TestUpdate(t *testing.T) {
+	tests := []struct {
+		name string
+		change func(*common.OrgInfo)
+		wantAccount string 	//修改后可以查到的账户
+		goneAccount string 	//修改后查不到的账户
+	}{
+		{name: "keep certificate and account", change: func(o *common.OrgInfo) { o.Bank = "20003" }, wantAccount: "6222000010101"},
+		{name: "add account", change: func(o *common.OrgInfo) { o.Accounts = append(o.Accounts, "6222000010102") }, wantAccount: "6222000010102"},
+		{name: "replace account", change: func(o *common.OrgInfo) { o.Accounts = []string{"6222000010102"} }, wantAccount: "6222000010102", goneAccount: "6222000010101"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub, county := newTestStub(t)
+			tt.change(&county)
+			mustInvoke(t, stub, "update", county.OrgID, mustMarshal(t, county))
+
+			if orgInfo, err := query(stub, "queryByCertificate", county.Certificates[0]); err != nil || orgInfo.OrgID != county.OrgID || orgInfo.Bank != county.Bank {
+				t.Errorf("queryByCertificate: %+v, %v", orgInfo, err)
+			}
+			if orgInfo, err := query(stub, "queryByAccount", tt.wantAccount); err != nil || orgInfo.OrgID != county.OrgID {
+				t.Errorf("queryByAccount %s: %+v, %v", tt.wantAccount, orgInfo, err)
+			}
+			if tt.goneAccount != "" {
+				if _, err := query(stub, "queryByAccount", tt.goneAccount); err == nil {
+					t.Errorf("the old account %s still belongs to the organization", tt.goneAccount)
+				}
+			}
+		})
+	}
+}
+
+func TestDeactivate(t *testing.T) {
+	stub, county := newTestStub(t)
+	mustInvoke(t, stub, "deactivate", county.OrgID)
+
+	orgInfo, err := query(stub, "queryByAccount", county.Accounts[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if orgInfo.Active {
+		t.Fatal("the organization is still active")
+	}
+}
+
+//一个证书、一个账户只能属于一个机构
+func TestRegisterDuplicate(t *testing.T) {
+	stub, county := newTestStub(t)
+
+	other := county
+	other.OrgID = "10102"
+	other.Accounts = []string{"6222000010199"}
+	if _, err := invoke(stub, "register", other.OrgID, mustMarshal(t, other)); err == nil || !strings.Contains(err.Error(), "already belongs") {
+		t.Errorf("registered a certificate of another organization: %v", err)
+	}
+
+	other.Certificates = nil
+	other.Accounts = county.Accounts
+	if _, err := invoke(stub, "register", other.OrgID, mustMarshal(t, other)); err == nil || !strings.Contains(err.Error(), "already belongs") {
+		t.Errorf("registered an account of another organization: %v", err)
+	}
+}
+
+func TestAdmin(t *testing.T) {
+	stub, county := newTestStub(t)
+
+	stub.SetCaller(county.OrgID)
+	if _, err := stub.MockInvoke("deactivate", []string{county.OrgID, county.OrgID}); err == nil {
+		t.Fatal("an organization deactivated itself")
+	}
+
+	//管理员的证书也要使用部署时的操作人编号
+	stub.SetCaller(admin)
+	if _, err := stub.MockInvoke("deactivate", []string{county.OrgID, "someone"}); err == nil {
+		t.Fatal("the administrator called with another operator")
+	}
+}
+
+//修改机构与其他链码一样记录操作
+func TestRecordOperation(t *testing.T) {
+	stub, county := newTestStub(t)
+	mustInvoke(t, stub, "deactivate", county.OrgID)
+
+	iter, err := stub.GetStateByPartialCompositeKey("Operation", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer iter.Close()
+
+	var functions []string
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var operation common.Operation
+		err = json.Unmarshal(kv.Value, &operation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if operation.Operator != admin {
+			t.Errorf("operator of %s = %s, want %s", operation.Function, operation.Operator, admin)
+		}
+		functions = append(functions, operation.Function)
+	}
+	sort.Strings(functions)
+	if strings.Join(functions, ",") != "deactivate,init,register" {
+		t.Fatalf("recorded operations %v", functions)
+	}
+}
@@ -0,0 +1,75 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+//校验参数个数，counts为允许的个数，例如CheckArgs(args, 1, 3)
+func CheckArgs(args []string, counts ...int) error {
+	for _, count := range counts {
+		if len(args) == count {
+			return nil
+		}
+	}
+
+	expecting := ""
+	for i, count := range counts {
+		if i > 0 {
+			expecting = expecting + " or "
+		}
+		expecting = expecting + strconv.Itoa(count)
+	}
+	return errors.New("Incorrect number of arguments. Expecting " + expecting)
+}
+
+//操作人编号，所有函数的最后一个参数都是操作人编号
+func Operator(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return args[len(args)-1]
+}
+
+//带错误码的错误，Code为错误码，Message为错误说明，以json字符串的形式返回给调用方
+type CodedError struct {
+	Code string
+	Message string
+}
+
+func (e *CodedError) Error() string {
+	b, _ := json.Marshal(e)
+	return string(b)
+}
+
+func NewError(code string, message string) error {
+	return &CodedError{Code: code, Message: message}
+}
+
+//把链码函数的返回结果转换成fabric的响应，出错时返回shim.Error，错误信息原样返回给调用方
+func Respond(payload []byte, err error) pb.Response {
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(payload)
+}
@@ -0,0 +1,137 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"errors"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+//链码函数：按函数名执行，返回结果或错误
+type Handler func(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error)
+
+//各链码Invoke的公共部分：权限表中的函数修改账本，其他函数为查询；查询不校验操作人，也不记录操作
+//修改账本的函数先按权限表校验操作人，执行成功后记录操作，与状态变更在同一个交易中写入
+func Invoke(stub shim.ChaincodeStubInterface, permissions map[string][]string, invoke Handler, query Handler) pb.Response {
+	function, args := stub.GetFunctionAndParameters()
+	if _, ok := permissions[function]; !ok {
+		return Respond(query(stub, function, args))
+	}
+	return Respond(invokeWithPermission(stub, permissions, invoke, function, args))
+}
+
+func invokeWithPermission(stub shim.ChaincodeStubInterface, permissions map[string][]string, invoke Handler, function string, args []string) ([]byte, error) {
+	//校验操作人权限
+	err := CheckPermission(stub, permissions, function, args)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := invoke(stub, function, args)
+	if err != nil {
+		return nil, err
+	}
+
+	//记录操作人
+	err = RecordOperation(stub, function, args)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+//各链码Init的公共部分：把部署者记为管理员，并记录操作人
+func RecordInit(stub shim.ChaincodeStubInterface, args []string) error {
+	err := RecordAdmin(stub, Operator(args))
+	if err != nil {
+		return err
+	}
+	return RecordOperation(stub, "init", args)
+}
+
+//校验操作人是否有权限调用该函数，permissions为函数名 → 允许调用的机构角色，没有配置角色的函数在函数内部判断权限
+//角色为RoleAdmin的函数（如setOrgRegistry）校验调用者和操作人是否为部署时记录的管理员，调用时可能还没有机构注册链码，无法通过它校验操作人
+func CheckPermission(stub shim.ChaincodeStubInterface, permissions map[string][]string, function string, args []string) error {
+	roles, ok := permissions[function]
+	if !ok {
+		return errors.New("no such a method on this chaincode")
+	}
+	if len(args) == 0 {
+		return errors.New("Incorrect number of arguments. Expecting operator")
+	}
+
+	if len(roles) == 1 && roles[0] == RoleAdmin {
+		return CheckAdmin(stub, Operator(args))
+	}
+
+	//操作人必须是交易证书对应的机构
+	operator := Operator(args)
+	err := CheckOperator(stub, operator)
+	if err != nil {
+		return err
+	}
+
+	if roles == nil {
+		return nil
+	}
+
+	operatorRole, err := GetOrgRole(stub, operator)
+	if err != nil {
+		return err
+	}
+	for _, role := range roles {
+		if role == operatorRole {
+			return nil
+		}
+	}
+	return errors.New("The operator " + operator + " has no permission to call " + function)
+}
+
+//操作记录以交易ID为组合键的属性存储
+const keyOperation = "Operation"
+
+//操作记录结构体，每次修改账本都记录操作人、时间、调用的函数和参数
+type Operation struct {
+	TxID string 	//交易ID
+	Time string 	//交易时间
+	Operator string 	//操作人编号
+	Function string 	//调用的函数
+	Args []string 	//调用参数
+}
+
+//记录操作，与状态变更在同一个交易中写入账本
+func RecordOperation(stub shim.ChaincodeStubInterface, function string, args []string) error {
+	var operation Operation
+	var err error
+
+	operation.TxID = stub.GetTxID()
+	operation.Time, err = TxTime(stub)
+	if err != nil {
+		return err
+	}
+	operation.Operator = Operator(args)
+	operation.Function = function
+	operation.Args = args
+
+	key, err := CreateCompositeKey(stub, keyOperation, operation.TxID)
+	if err != nil {
+		return err
+	}
+	return PutJSON(stub, key, operation)
+}
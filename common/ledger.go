@@ -0,0 +1,97 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//数字汇票、项目、募资结构、机构注册四个链码共用的账本操作、参数校验、错误类型和机构相关的函数
+//各链码的main包引用本包，不再各自复制一份
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+//生成组合键，由stub.CreateCompositeKey生成并校验：属性必须是合法的utf8字符串，不能包含U+0000和U+10FFFF
+//属性可能来自调用参数（机构ID、项目ID等），不校验时包含\x00的属性会生成与其他键混淆的组合键，所以出错时必须返回错误
+func CreateCompositeKey(stub shim.ChaincodeStubInterface, objectType string, attributes ...string) (string, error) {
+	return stub.CreateCompositeKey(objectType, attributes)
+}
+
+//调用同一通道上的其他链码，args的第一个为函数名；被调用链码返回错误时转换成error
+//查询也通过InvokeChaincode调用，被调用链码的写入与本交易一起提交
+func CallChaincode(stub shim.ChaincodeStubInterface, chaincodeName string, args ...string) ([]byte, error) {
+	var invokeArgs [][]byte
+	for _, arg := range args {
+		invokeArgs = append(invokeArgs, []byte(arg))
+	}
+
+	response := stub.InvokeChaincode(chaincodeName, invokeArgs, "")
+	if response.Status >= shim.ERRORTHRESHOLD {
+		return nil, errors.New(response.Message)
+	}
+	return response.Payload, nil
+}
+
+//读取json格式保存的状态，键不存在时返回false
+func GetJSON(stub shim.ChaincodeStubInterface, key string, v interface{}) (bool, error) {
+	b, err := stub.GetState(key)
+	if err != nil {
+		return false, errors.New("Failed to get state")
+	}
+	if b == nil {
+		return false, nil
+	}
+	err = json.Unmarshal(b, v)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+//以json格式保存状态
+func PutJSON(stub shim.ChaincodeStubInterface, key string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	// Write the state to the ledger
+	return stub.PutState(key, b)
+}
+
+//取交易时间，格式为RFC3339
+func TxTime(stub shim.ChaincodeStubInterface) (string, error) {
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return "", errors.New("Failed to get transaction timestamp")
+	}
+	return time.Unix(ts.Seconds, int64(ts.Nanos)).UTC().Format(time.RFC3339), nil
+}
+
+//查询结果格式版本，查询返回的文档字段有不兼容的变化时加1
+const QuerySchemaVersion = 1
+
+//录入的字符串是json时原样返回，否则转换成json字符串，用于查询结果中原样保存的字段
+func RawJSON(s string) json.RawMessage {
+	var v interface{}
+	if s != "" && json.Unmarshal([]byte(s), &v) == nil {
+		return json.RawMessage(s)
+	}
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
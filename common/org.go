@@ -0,0 +1,296 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+//机构角色，与机构注册链码中的定义一致
+const (
+	RoleCounty = "county"	//县 原101+县ID
+	RoleSPV = "spv"	//SPV 原102+县ID
+	RoleCountyGovernment = "countyGovernment"	//县政府 原103+县ID
+	RoleOffice = "office"	//指挥部办公室 原202+县ID
+	RoleProvince = "province"	//省 原20003
+	RolePartnership = "partnership"	//有限合伙 原20005
+	RoleICBC = "icbc"	//ICBC 原20006
+	RoleProjectCompany = "projectCompany"	//项目公司 原3+xxx
+	RoleAdmin = "admin"	//不是机构角色，权限表中表示只能由链码管理员（部署者）调用
+)
+
+//机构信息结构体
+type OrgInfo struct {
+	OrgID string 	//机构ID
+	Role string 	//机构角色
+	County string 	//所属县ID，县、SPV、县政府、指挥部办公室必填
+	Accounts []string 	//银行账户
+	Bank string 	//开户银行机构ID，只有该银行可以对该机构持有的汇票做转账和平账
+	MSPID string 	//机构所属的MSP ID，交易证书由该MSP签发时才采信证书属性orgID
+	Certificates []string 	//机构证书的sha256指纹（十六进制小写），用于根据交易证书确定调用者
+	Active bool 	//是否有效
+}
+
+//按原来的机构ID规则解析角色和所属县：县101+县ID SPV102+县ID 县政府103+县ID 指挥部办公室202+县ID 省20003 有限合伙20005 ICBC20006 项目公司3+xxx
+//机构注册时没有填写角色的按此规则补全，不符合规则时返回false
+func ParseOrgID(orgID string) (string, string, bool) {
+	switch orgID {
+	case "20003":
+		return RoleProvince, "", true
+	case "20005":
+		return RolePartnership, "", true
+	case "20006":
+		return RoleICBC, "", true
+	}
+	prefixes := []struct {
+		Prefix string
+		Role string
+	}{
+		{"101", RoleCounty},
+		{"102", RoleSPV},
+		{"103", RoleCountyGovernment},
+		{"202", RoleOffice},
+	}
+	for _, p := range prefixes {
+		if strings.HasPrefix(orgID, p.Prefix) && len(orgID) > len(p.Prefix) {
+			return p.Role, orgID[len(p.Prefix):], true
+		}
+	}
+	if strings.HasPrefix(orgID, "3") && len(orgID) > 1 {
+		return RoleProjectCompany, "", true
+	}
+	return "", "", false
+}
+
+//管理员保存在该键下，Init再次执行时（1.4升级，2.x带--init-required提交新定义后的--isInit调用）管理员改为该次调用者
+const keyAdmin = "Admin"
+
+//管理员结构体，管理员不是注册的机构，操作人编号不能通过机构注册链码校验，所以和证书指纹一起记录
+type adminStruct struct {
+	Operator string 	//部署时的操作人编号，管理员调用时操作人必须与之一致
+	Certificate string 	//部署者的证书指纹
+}
+
+//记录部署者：把交易证书指纹和操作人编号记为管理员，只有管理员可以设置机构注册链码等链码配置
+func RecordAdmin(stub shim.ChaincodeStubInterface, operator string) error {
+	cert, err := CallerCertificate(stub)
+	if err != nil {
+		return err
+	}
+	if operator == "" {
+		return errors.New("The operator is empty")
+	}
+
+	return PutJSON(stub, keyAdmin, adminStruct{Operator: operator, Certificate: CertFingerprint(cert)})
+}
+
+//校验调用者是否为管理员，并且操作人是部署时记录的操作人；没有记录管理员时（部署后没有升级过）拒绝调用
+func CheckAdmin(stub shim.ChaincodeStubInterface, operator string) error {
+	var admin adminStruct
+
+	found, err := GetJSON(stub, keyAdmin, &admin)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.New("The administrator is not set, upgrade the chaincode to set it")
+	}
+
+	cert, err := CallerCertificate(stub)
+	if err != nil {
+		return err
+	}
+	if CertFingerprint(cert) != admin.Certificate {
+		return errors.New("The caller is not the administrator of the chaincode")
+	}
+	if operator != admin.Operator {
+		return errors.New("The operator " + operator + " is not the administrator " + admin.Operator)
+	}
+	return nil
+}
+
+//机构注册链码名称保存在该键下
+const keyOrgRegistry = "OrgRegistry"
+
+//设置机构注册链码 传入参数有2个：机构注册链码名称，操作人编号；只能由管理员调用
+func SetOrgRegistry(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	err := CheckArgs(args, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	//机构注册链码只能设置一次，设置之后所有权限都依赖它判断
+	registry, err := stub.GetState(keyOrgRegistry)
+	if err != nil {
+		return nil, errors.New("Failed to get state")
+	}
+	if registry != nil {
+		return nil, errors.New("The organization registry is already set")
+	}
+
+	// Write the state to the ledger
+	err = stub.PutState(keyOrgRegistry, []byte(args[0]))
+	if err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+//调用机构注册链码的查询函数（query、queryByCertificate、queryByAccount），key为机构ID、证书指纹或银行账户
+func QueryOrgRegistry(stub shim.ChaincodeStubInterface, function string, key string) (OrgInfo, error) {
+	var orgInfo OrgInfo
+
+	registry, err := stub.GetState(keyOrgRegistry)
+	if err != nil {
+		return orgInfo, errors.New("Failed to get state")
+	}
+	if registry == nil {
+		return orgInfo, errors.New("The organization registry is not set")
+	}
+
+	orgInfoByte, err := CallChaincode(stub, string(registry), function, key)
+	if err != nil {
+		return orgInfo, errors.New("Failed to query organization " + key + ": " + err.Error())
+	}
+	err = json.Unmarshal(orgInfoByte, &orgInfo)
+	if err != nil {
+		return orgInfo, err
+	}
+	return orgInfo, nil
+}
+
+//通过机构注册链码查询机构信息，停用的机构视为不存在
+func GetOrgInfo(stub shim.ChaincodeStubInterface, orgID string) (OrgInfo, error) {
+	return activeOrgInfo(QueryOrgRegistry(stub, "query", orgID))
+}
+
+//通过机构注册链码查询银行账户所属的机构，停用的机构视为不存在
+func GetOrgInfoByAccount(stub shim.ChaincodeStubInterface, account string) (OrgInfo, error) {
+	return activeOrgInfo(QueryOrgRegistry(stub, "queryByAccount", account))
+}
+
+func activeOrgInfo(orgInfo OrgInfo, err error) (OrgInfo, error) {
+	if err != nil {
+		return orgInfo, err
+	}
+	if !orgInfo.Active {
+		return orgInfo, errors.New("The organization " + orgInfo.OrgID + " is not active")
+	}
+	return orgInfo, nil
+}
+
+//查询机构角色
+func GetOrgRole(stub shim.ChaincodeStubInterface, orgID string) (string, error) {
+	orgInfo, err := GetOrgInfo(stub, orgID)
+	if err != nil {
+		return "", err
+	}
+	return orgInfo.Role, nil
+}
+
+//交易提交者的证书（DER编码），从交易的creator中解析
+func CallerCertificate(stub shim.ChaincodeStubInterface) ([]byte, error) {
+	cert, err := cid.GetX509Certificate(stub)
+	if err != nil || cert == nil {
+		return nil, errors.New("Failed to get caller certificate")
+	}
+	return cert.Raw, nil
+}
+
+//证书指纹：DER编码证书的sha256，十六进制小写；传入PEM格式时先解码
+func CertFingerprint(cert []byte) string {
+	block, _ := pem.Decode(cert)
+	if block != nil {
+		cert = block.Bytes
+	}
+	sum := sha256.Sum256(cert)
+	return hex.EncodeToString(sum[:])
+}
+
+//根据交易证书确定调用者的机构ID
+//优先使用证书属性orgID，证书中没有该属性时，用证书指纹到机构注册链码中查询
+//证书属性由签发证书的CA写入，任何MSP的CA都可以写入任意机构ID，所以只有交易证书的MSP与机构注册的MSP一致，
+//或者证书指纹已经登记在该机构下时，才采信该属性
+func CallerOrgID(stub shim.ChaincodeStubInterface) (string, error) {
+	orgInfo, err := callerOrgInfo(stub)
+	if err != nil {
+		return "", err
+	}
+	return orgInfo.OrgID, nil
+}
+
+//交易证书对应机构的注册信息，规则见CallerOrgID，不判断机构是否有效
+func callerOrgInfo(stub shim.ChaincodeStubInterface) (OrgInfo, error) {
+	cert, err := CallerCertificate(stub)
+	if err != nil {
+		return OrgInfo{}, err
+	}
+	fingerprint := CertFingerprint(cert)
+
+	orgID, found, err := cid.GetAttributeValue(stub, "orgID")
+	if err == nil && found && orgID != "" {
+		orgInfo, err := QueryOrgRegistry(stub, "query", orgID)
+		if err != nil {
+			return orgInfo, err
+		}
+		mspID, err := cid.GetMSPID(stub)
+		if err != nil {
+			return orgInfo, errors.New("Failed to get caller MSP ID")
+		}
+		if (orgInfo.MSPID == "" || mspID != orgInfo.MSPID) && !containsString(orgInfo.Certificates, fingerprint) {
+			return orgInfo, errors.New("The caller certificate of MSP " + mspID + " is not issued for organization " + orgID)
+		}
+		return orgInfo, nil
+	}
+
+	orgInfo, err := QueryOrgRegistry(stub, "queryByCertificate", fingerprint)
+	if err != nil {
+		return orgInfo, errors.New("The caller certificate " + fingerprint + " does not belong to any organization")
+	}
+	return orgInfo, nil
+}
+
+//不区分大小写判断values中是否包含value，证书指纹按十六进制比较
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+//校验参数中的操作人与交易证书对应的机构是否一致，停用的机构不能再操作
+//没有配置角色的函数（transfer、settle等）只通过这里校验调用者，所以在这里判断机构是否有效
+func CheckOperator(stub shim.ChaincodeStubInterface, operator string) error {
+	caller, err := activeOrgInfo(callerOrgInfo(stub))
+	if err != nil {
+		return err
+	}
+	if caller.OrgID != operator {
+		return errors.New("The operator " + operator + " does not match the caller certificate of organization " + caller.OrgID)
+	}
+	return nil
+}
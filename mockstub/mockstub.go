@@ -0,0 +1,435 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//内存中的链码stub，用于单元测试，不需要启动peer
+//账本为内存中的map，每次MockInit、MockInvoke、MockQuery是一个交易：分配交易ID，使用Time作为交易时间
+//与fabric相同，交易中的写入在交易成功后才提交，交易中读到的都是交易开始前的状态；返回错误时丢弃本次交易的所有写入
+//调用者通过SetCaller设置证书属性orgID和MSP，或者通过SetCallerCertificate设置交易证书，链码通过GetCreator（cid库）读取
+//其他链码通过MockPeerChaincode按名称注册，InvokeChaincode在同一个交易中调用，调用者和交易时间相同
+package mockstub
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+//没有通过SetCaller设置调用者时，调用者所属的MSP
+const DefaultMSPID = "Org1MSP"
+
+//fabric-ca签发的证书中存放属性的扩展，值为json：{"attrs":{"属性名":"属性值"}}
+var attrOID = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7, 8, 1}
+
+//链码事件，交易成功时记录
+type Event struct {
+	TxID string 	//交易ID
+	Name string 	//事件名称
+	Payload []byte 	//事件内容
+}
+
+//内存中的链码stub
+//没有实现的方法（表操作、属性校验、签名校验等）由嵌入的接口提供，调用时会panic，链码中没有用到
+type MockStub struct {
+	shim.ChaincodeStubInterface
+
+	Name string 	//链码名称
+	State map[string][]byte 	//已提交的账本状态
+	Certificate []byte 	//交易证书，PEM格式
+	MSPID string 	//调用者所属的MSP ID
+	Time time.Time 	//交易时间，测试中按需修改
+	Events []Event 	//成功的交易发出的事件，按交易顺序
+
+	cc shim.Chaincode 	//被测试的链码
+	peers map[string]*MockStub 	//其他链码，链码名称 → stub
+	certs map[string][]byte 	//SetCaller生成的证书，机构ID → 证书，同一机构每次使用同一个证书
+	txID string 	//当前交易ID
+	txCount int 	//已分配的交易数
+	args []string 	//当前交易的函数名和参数
+	event *Event 	//当前交易发出的事件，fabric每个交易只能发出一个事件，后发出的覆盖先发出的
+	readOnly bool 	//查询时不能修改账本
+	writes map[string][]byte 	//当前交易的写入，值为nil表示删除，交易成功后写入State
+}
+
+func NewMockStub(name string, cc shim.Chaincode) *MockStub {
+	return &MockStub{
+		Name: name,
+		State: make(map[string][]byte),
+		MSPID: DefaultMSPID,
+		Time: time.Date(2017, 1, 11, 10, 0, 0, 0, time.UTC),
+		cc: cc,
+		peers: make(map[string]*MockStub),
+		certs: make(map[string][]byte),
+		writes: make(map[string][]byte),
+	}
+}
+
+//设置调用者的机构ID，以带属性orgID的自签名证书作为交易证书，每个机构第一次设置时生成
+//每个机构一个MSP，MSP ID为机构ID+"MSP"，与testdata/orgs.json一致；测试中可以再修改MSPID冒充其他机构
+func (s *MockStub) SetCaller(orgID string) {
+	cert, ok := s.certs[orgID]
+	if !ok {
+		var err error
+		cert, err = newCertificate(map[string]string{"orgID": orgID})
+		if err != nil {
+			panic(err)
+		}
+		s.certs[orgID] = cert
+	}
+	s.Certificate = cert
+	s.MSPID = orgID + "MSP"
+}
+
+//设置调用者的交易证书（PEM或DER格式），证书中没有属性时，链码需要通过证书指纹确定调用者；MSPID不变
+func (s *MockStub) SetCallerCertificate(cert []byte) {
+	block, _ := pem.Decode(cert)
+	if block == nil {
+		cert = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert})
+	}
+	s.Certificate = cert
+}
+
+//生成自签名证书，attrs不为空时按fabric-ca的格式写入属性扩展
+func newCertificate(attrs map[string]string) ([]byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject: pkix.Name{CommonName: attrs["orgID"]},
+		NotBefore: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter: time.Date(2037, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if len(attrs) > 0 {
+		value, err := json.Marshal(map[string]map[string]string{"attrs": attrs})
+		if err != nil {
+			return nil, err
+		}
+		template.ExtraExtensions = []pkix.Extension{{Id: attrOID, Value: value}}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
+
+//按名称注册其他链码，供InvokeChaincode调用
+func (s *MockStub) MockPeerChaincode(name string, peer *MockStub) {
+	s.peers[name] = peer
+}
+
+//部署链码，链码返回错误响应时转换为error
+func (s *MockStub) MockInit(function string, args []string) ([]byte, error) {
+	return responseResult(s.transaction(function, args, false, s.cc.Init))
+}
+
+//调用链码
+func (s *MockStub) MockInvoke(function string, args []string) ([]byte, error) {
+	return responseResult(s.transaction(function, args, false, s.cc.Invoke))
+}
+
+//查询链码，相当于peer chaincode query：通过Invoke调用，查询中修改账本或发出事件会返回错误
+func (s *MockStub) MockQuery(function string, args []string) ([]byte, error) {
+	return responseResult(s.transaction(function, args, true, s.cc.Invoke))
+}
+
+func responseResult(response pb.Response) ([]byte, error) {
+	if response.Status >= shim.ERRORTHRESHOLD {
+		return nil, errors.New(response.Message)
+	}
+	return response.Payload, nil
+}
+
+//最后一个成功的交易发出的事件，没有时返回nil
+func (s *MockStub) LastEvent() *Event {
+	if len(s.Events) == 0 {
+		return nil
+	}
+	return &s.Events[len(s.Events)-1]
+}
+
+//执行一个交易：成功时提交本链码和被调用链码的写入和事件，返回错误时全部丢弃
+func (s *MockStub) transaction(function string, args []string, readOnly bool, call func(shim.ChaincodeStubInterface) pb.Response) pb.Response {
+	s.txCount++
+	//交易ID补齐位数，按字符串排序即按交易顺序：测试中交易时间相同时，以交易时间、交易ID为键的历史仍按交易顺序排列
+	s.txID = fmt.Sprintf("%s-tx%06d", s.Name, s.txCount)
+	response := s.run(function, args, readOnly, call)
+	s.finish(response.Status < shim.ERRORTHRESHOLD, make(map[*MockStub]bool))
+	return response
+}
+
+//在当前交易中执行链码函数，其他链码调用时也使用调用方的交易ID
+//返回错误时丢弃本次调用的写入和事件，同一交易中之前调用的写入保留
+func (s *MockStub) run(function string, args []string, readOnly bool, call func(shim.ChaincodeStubInterface) pb.Response) pb.Response {
+	writes := make(map[string][]byte, len(s.writes))
+	for key, value := range s.writes {
+		writes[key] = value
+	}
+	event := s.event
+	s.args = append([]string{function}, args...)
+	s.readOnly = readOnly
+
+	response := call(s)
+	s.readOnly = false
+	if response.Status >= shim.ERRORTHRESHOLD {
+		s.writes = writes
+		s.event = event
+	}
+	return response
+}
+
+//交易结束，提交或丢弃本链码和被调用链码的写入和事件
+func (s *MockStub) finish(commit bool, finished map[*MockStub]bool) {
+	if finished[s] {
+		return
+	}
+	finished[s] = true
+
+	if commit {
+		for key, value := range s.writes {
+			if value == nil {
+				delete(s.State, key)
+			} else {
+				s.State[key] = value
+			}
+		}
+		if s.event != nil {
+			s.Events = append(s.Events, *s.event)
+		}
+	}
+	s.writes = make(map[string][]byte)
+	s.event = nil
+	for _, peer := range s.peers {
+		peer.finish(commit, finished)
+	}
+}
+
+func (s *MockStub) GetArgs() [][]byte {
+	var args [][]byte
+	for _, arg := range s.args {
+		args = append(args, []byte(arg))
+	}
+	return args
+}
+
+func (s *MockStub) GetStringArgs() []string {
+	return s.args
+}
+
+func (s *MockStub) GetFunctionAndParameters() (string, []string) {
+	if len(s.args) == 0 {
+		return "", []string{}
+	}
+	return s.args[0], append([]string{}, s.args[1:]...)
+}
+
+func (s *MockStub) GetTxID() string {
+	return s.txID
+}
+
+//与fabric相同，只读已提交的状态，读不到本交易中的写入
+func (s *MockStub) GetState(key string) ([]byte, error) {
+	return s.State[key], nil
+}
+
+func (s *MockStub) PutState(key string, value []byte) error {
+	if s.readOnly {
+		return errors.New("Can not put state in a query")
+	}
+	if key == "" {
+		return errors.New("The key is empty")
+	}
+	s.writes[key] = append([]byte{}, value...)
+	return nil
+}
+
+func (s *MockStub) DelState(key string) error {
+	if s.readOnly {
+		return errors.New("Can not delete state in a query")
+	}
+	s.writes[key] = nil
+	return nil
+}
+
+//按键排序返回[startKey, endKey)之间的状态，与fabric相同，不能用于组合键；返回的是调用时的快照
+func (s *MockStub) GetStateByRange(startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	if strings.HasPrefix(startKey, compositeKeyNamespace) || strings.HasPrefix(endKey, compositeKeyNamespace) {
+		return nil, errors.New("The range query can not be used on composite keys")
+	}
+	return s.query(func(key string) bool {
+		return !strings.HasPrefix(key, compositeKeyNamespace) && key >= startKey && (endKey == "" || key < endKey)
+	}), nil
+}
+
+//按键排序返回以给定类型和属性开头的组合键的状态
+func (s *MockStub) GetStateByPartialCompositeKey(objectType string, attributes []string) (shim.StateQueryIteratorInterface, error) {
+	prefix, err := s.CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		return nil, err
+	}
+	return s.query(func(key string) bool {
+		return strings.HasPrefix(key, prefix)
+	}), nil
+}
+
+//分页查询以给定类型和属性开头的组合键，与fabric的LevelDB相同：bookmark为本页第一个键，为空时从头开始；
+//返回的Bookmark为下一页第一个键，没有下一页时为空。与fabric相同，已经写入过的交易中不能分页查询
+func (s *MockStub) GetStateByPartialCompositeKeyWithPagination(objectType string, keys []string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	if len(s.writes) > 0 {
+		return nil, nil, errors.New("Paginated queries are not supported in a transaction that performs writes")
+	}
+	if pageSize <= 0 {
+		return nil, nil, errors.New("The page size must be positive")
+	}
+	prefix, err := s.CreateCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	all := s.query(func(key string) bool {
+		return strings.HasPrefix(key, prefix) && key >= bookmark
+	})
+
+	page := &mockIterator{}
+	metadata := &pb.QueryResponseMetadata{}
+	for i, key := range all.keys {
+		if i == int(pageSize) {
+			metadata.Bookmark = key
+			break
+		}
+		page.keys = append(page.keys, key)
+		page.values = append(page.values, all.values[i])
+	}
+	metadata.FetchedRecordsCount = int32(len(page.keys))
+	return page, metadata, nil
+}
+
+func (s *MockStub) query(match func(key string) bool) *mockIterator {
+	iter := &mockIterator{}
+	for key := range s.State {
+		if match(key) {
+			iter.keys = append(iter.keys, key)
+		}
+	}
+	sort.Strings(iter.keys)
+	for _, key := range iter.keys {
+		iter.values = append(iter.values, s.State[key])
+	}
+	return iter
+}
+
+//组合键的格式与fabric相同："\x00" + 类型 + "\x00" + 属性1 + "\x00" + ...
+const compositeKeyNamespace = "\x00"
+
+//与fabric相同，属性不是合法的utf8字符串或包含U+0000、U+10FFFF时返回错误
+func (s *MockStub) CreateCompositeKey(objectType string, attributes []string) (string, error) {
+	return shim.CreateCompositeKey(objectType, attributes)
+}
+
+func (s *MockStub) SplitCompositeKey(compositeKey string) (string, []string, error) {
+	if !strings.HasPrefix(compositeKey, compositeKeyNamespace) || !strings.HasSuffix(compositeKey, "\x00") {
+		return "", nil, errors.New("The key " + compositeKey + " is not a composite key")
+	}
+	components := strings.Split(compositeKey[1:len(compositeKey)-1], "\x00")
+	return components[0], components[1:], nil
+}
+
+//调用其他链码，第一个参数为函数名；被调用的链码与调用方使用同一个交易证书、交易ID和交易时间
+//被调用的链码返回错误时丢弃它本次调用的写入；它的写入与调用方一起在交易结束时提交或丢弃
+//调用方在查询中时，被调用的链码也不能修改账本
+func (s *MockStub) InvokeChaincode(chaincodeName string, args [][]byte, channel string) pb.Response {
+	peer, ok := s.peers[chaincodeName]
+	if !ok {
+		return shim.Error("The chaincode " + chaincodeName + " is not found")
+	}
+	if len(args) == 0 {
+		return shim.Error("The function of chaincode " + chaincodeName + " is empty")
+	}
+	var stringArgs []string
+	for _, arg := range args[1:] {
+		stringArgs = append(stringArgs, string(arg))
+	}
+
+	peer.Certificate = s.Certificate
+	peer.MSPID = s.MSPID
+	peer.Time = s.Time
+	peer.txID = s.txID
+	return peer.run(string(args[0]), stringArgs, s.readOnly, peer.cc.Invoke)
+}
+
+//交易提交者：MSP ID和PEM格式的交易证书，没有设置调用者时返回错误
+func (s *MockStub) GetCreator() ([]byte, error) {
+	if len(s.Certificate) == 0 {
+		return nil, errors.New("The caller is not set")
+	}
+	return proto.Marshal(&msp.SerializedIdentity{Mspid: s.MSPID, IdBytes: s.Certificate})
+}
+
+func (s *MockStub) GetTxTimestamp() (*timestamp.Timestamp, error) {
+	return &timestamp.Timestamp{Seconds: s.Time.Unix(), Nanos: int32(s.Time.Nanosecond())}, nil
+}
+
+func (s *MockStub) SetEvent(name string, payload []byte) error {
+	if s.readOnly {
+		return errors.New("Can not set event in a query")
+	}
+	if name == "" {
+		return errors.New("The event name is empty")
+	}
+	s.event = &Event{TxID: s.txID, Name: name, Payload: payload}
+	return nil
+}
+
+//状态范围查询的迭代器
+type mockIterator struct {
+	keys []string
+	values [][]byte
+	current int
+}
+
+func (iter *mockIterator) HasNext() bool {
+	return iter.current < len(iter.keys)
+}
+
+func (iter *mockIterator) Next() (*queryresult.KV, error) {
+	if !iter.HasNext() {
+		return nil, errors.New("No more states")
+	}
+	iter.current++
+	return &queryresult.KV{Key: iter.keys[iter.current-1], Value: iter.values[iter.current-1]}, nil
+}
+
+func (iter *mockIterator) Close() error {
+	return nil
+}
@@ -0,0 +1,110 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mockstub
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/xyjxyjxyj/MySC/common"
+)
+
+//内存中的机构注册链码，提供与机构注册链码相同的query、queryByCertificate、queryByAccount查询
+//测试其他链码时代替机构注册链码，机构信息直接从testdata/orgs.json读入，不需要先部署、注册
+type OrgRegistry struct {
+	Orgs []common.OrgInfo
+}
+
+//读取testdata/orgs.json格式的机构注册信息，所有机构都视为已注册、有效
+func LoadOrgRegistry(path string) (*OrgRegistry, error) {
+	var orgs struct {
+		Organizations []common.OrgInfo
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(b, &orgs)
+	if err != nil {
+		return nil, err
+	}
+
+	registry := &OrgRegistry{Orgs: orgs.Organizations}
+	for i := range registry.Orgs {
+		registry.Orgs[i].Active = true
+	}
+	return registry, nil
+}
+
+//按机构ID查找机构，没有时返回nil，测试中可以直接修改返回的机构信息
+func (r *OrgRegistry) Org(orgID string) *common.OrgInfo {
+	for i := range r.Orgs {
+		if r.Orgs[i].OrgID == orgID {
+			return &r.Orgs[i]
+		}
+	}
+	return nil
+}
+
+func (r *OrgRegistry) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	return shim.Success(nil)
+}
+
+//只提供查询
+func (r *OrgRegistry) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	function, args := stub.GetFunctionAndParameters()
+	return common.Respond(r.query(function, args))
+}
+
+func (r *OrgRegistry) query(function string, args []string) ([]byte, error) {
+	err := common.CheckArgs(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, orgInfo := range r.Orgs {
+		var found bool
+		if function == "query" {
+			found = orgInfo.OrgID == args[0]
+		}else if function == "queryByCertificate" {
+			found = contains(orgInfo.Certificates, args[0])
+		}else if function == "queryByAccount" {
+			found = contains(orgInfo.Accounts, args[0])
+		}else {
+			return nil, errors.New("Invalid query function name. Expecting \"query\", \"queryByCertificate\" or \"queryByAccount\"")
+		}
+		if found {
+			return json.Marshal(orgInfo)
+		}
+	}
+	return nil, errors.New("{\"Error\":\"Failed to get organization " + args[0] + "\"}")
+}
+
+//不区分大小写判断values中是否包含value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,233 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//金额类型，数字汇票、项目、募资结构三个链码共用
+//金额以最小货币单位（分）的整数保存，不会丢失角分，运算时检查溢出和币种
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+//默认币种，没有写币种的金额都按人民币处理
+const DefaultCurrency = "CNY"
+
+//小数位数，1元=100分
+const minorDigits = 2
+
+const minorPerMajor = 100
+
+var (
+	ErrInvalid = errors.New("money: invalid amount")	//金额格式不正确
+	ErrOverflow = errors.New("money: amount overflow")	//金额超出范围
+	ErrCurrencyMismatch = errors.New("money: currency mismatch")	//币种不同，不能运算或比较
+)
+
+//金额
+type Money struct {
+	Minor int64 	//以最小货币单位计的金额
+	Currency string 	//币种代码，ISO 4217
+}
+
+//以最小货币单位生成金额，币种为空时使用默认币种
+func New(minor int64, currency string) Money {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	return Money{Minor: minor, Currency: currency}
+}
+
+//解析金额字符串，格式为 [-]整数[.最多两位小数][ 币种]，例如 "1000000"、"1234.5"、"1234.50 CNY"
+//没有写币种时使用默认币种
+func Parse(s string) (Money, error) {
+	var m Money
+
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 2 {
+		return m, ErrInvalid
+	}
+	m.Currency = DefaultCurrency
+	if len(fields) == 2 {
+		if !isCurrency(fields[1]) {
+			return m, ErrInvalid
+		}
+		m.Currency = fields[1]
+	}
+
+	amount := fields[0]
+	negative := false
+	if strings.HasPrefix(amount, "-") {
+		negative = true
+		amount = amount[1:]
+	}
+
+	major := amount
+	minor := ""
+	if i := strings.IndexByte(amount, '.'); i >= 0 {
+		major = amount[:i]
+		minor = amount[i+1:]
+		if len(minor) == 0 || len(minor) > minorDigits {
+			return m, ErrInvalid
+		}
+	}
+	if len(major) == 0 || !isDigits(major) || !isDigits(minor) {
+		return m, ErrInvalid
+	}
+	for len(minor) < minorDigits {
+		minor = minor + "0"
+	}
+
+	majorValue, err := strconv.ParseInt(major, 10, 64)
+	if err != nil {
+		return m, ErrOverflow
+	}
+	minorValue, _ := strconv.ParseInt(minor, 10, 64)
+	if majorValue > (math.MaxInt64-minorValue)/minorPerMajor {
+		return m, ErrOverflow
+	}
+	m.Minor = majorValue*minorPerMajor + minorValue
+	if negative {
+		m.Minor = -m.Minor
+	}
+	return m, nil
+}
+
+//规范的字符串形式，固定两位小数加币种，例如 "1234.50 CNY"
+func (m Money) String() string {
+	minor := m.Minor
+	sign := ""
+	if minor < 0 {
+		sign = "-"
+	}
+	//取绝对值时不能直接取反，否则math.MinInt64会溢出
+	major := minor / minorPerMajor
+	rest := minor % minorPerMajor
+	if major < 0 {
+		major = -major
+	}
+	if rest < 0 {
+		rest = -rest
+	}
+	restStr := strconv.FormatInt(rest, 10)
+	for len(restStr) < minorDigits {
+		restStr = "0" + restStr
+	}
+	return sign + strconv.FormatInt(major, 10) + "." + restStr + " " + m.currency()
+}
+
+//金额相加，币种不同或溢出时返回错误
+func (m Money) Add(o Money) (Money, error) {
+	if m.currency() != o.currency() {
+		return Money{}, ErrCurrencyMismatch
+	}
+	if (o.Minor > 0 && m.Minor > math.MaxInt64-o.Minor) || (o.Minor < 0 && m.Minor < math.MinInt64-o.Minor) {
+		return Money{}, ErrOverflow
+	}
+	return Money{Minor: m.Minor + o.Minor, Currency: m.currency()}, nil
+}
+
+//金额相减，币种不同或溢出时返回错误
+func (m Money) Sub(o Money) (Money, error) {
+	if o.Minor == math.MinInt64 {
+		return Money{}, ErrOverflow
+	}
+	return m.Add(Money{Minor: -o.Minor, Currency: o.Currency})
+}
+
+//比较金额，m小于、等于、大于o时分别返回-1、0、1，币种不同时返回错误
+func (m Money) Cmp(o Money) (int, error) {
+	if m.currency() != o.currency() {
+		return 0, ErrCurrencyMismatch
+	}
+	if m.Minor < o.Minor {
+		return -1, nil
+	}
+	if m.Minor > o.Minor {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+//金额和币种都相同
+func (m Money) Equal(o Money) bool {
+	c, err := m.Cmp(o)
+	return err == nil && c == 0
+}
+
+//是否大于0
+func (m Money) IsPositive() bool {
+	return m.Minor > 0
+}
+
+//json中以规范的字符串形式保存
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+//兼容原来以字符串保存的金额，例如 "1000000"，原来没有填写的空字符串为0；也接受json数字
+func (m *Money) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		var n json.Number
+		if err := json.Unmarshal(b, &n); err != nil {
+			return ErrInvalid
+		}
+		s = n.String()
+	}
+	if s == "" {
+		*m = New(0, "")
+		return nil
+	}
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+func (m Money) currency() string {
+	if m.Currency == "" {
+		return DefaultCurrency
+	}
+	return m.Currency
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+//币种代码为三个大写字母
+func isCurrency(s string) bool {
+	if len(s) != 3 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < 'A' || s[i] > 'Z' {
+			return false
+		}
+	}
+	return true
+}
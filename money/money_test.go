@@ -0,0 +1,167 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package money
+
+//金额类型的单元测试
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		in string
+		want Money
+		wantErr error
+	}{
+		{name: "integer", in: "1000000", want: Money{Minor: 100000000, Currency: "CNY"}},
+		{name: "one decimal", in: "1234.5", want: Money{Minor: 123450, Currency: "CNY"}},
+		{name: "two decimals with currency", in: "1234.56 USD", want: Money{Minor: 123456, Currency: "USD"}},
+		{name: "negative", in: "-0.01", want: Money{Minor: -1, Currency: "CNY"}},
+		{name: "surrounding spaces", in: "  12  CNY ", want: Money{Minor: 1200, Currency: "CNY"}},
+		{name: "largest amount", in: "92233720368547758.07", want: Money{Minor: math.MaxInt64, Currency: "CNY"}},
+		{name: "empty", in: "", wantErr: ErrInvalid},
+		{name: "three decimals", in: "1.234", wantErr: ErrInvalid},
+		{name: "no decimals after point", in: "1.", wantErr: ErrInvalid},
+		{name: "no integer part", in: ".5", wantErr: ErrInvalid},
+		{name: "letters", in: "12a", wantErr: ErrInvalid},
+		{name: "plus sign", in: "+12", wantErr: ErrInvalid},
+		{name: "lowercase currency", in: "12 cny", wantErr: ErrInvalid},
+		{name: "too many fields", in: "12 CNY x", wantErr: ErrInvalid},
+		{name: "overflow in minor units", in: "92233720368547758.08", wantErr: ErrOverflow},
+		{name: "overflow in integer part", in: "99999999999999999999", wantErr: ErrOverflow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.in)
+			if err != tt.wantErr {
+				t.Fatalf("Parse(%q) error = %v, want %v", tt.in, err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Fatalf("Parse(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		in Money
+		want string
+	}{
+		{in: New(123450, ""), want: "1234.50 CNY"},
+		{in: New(5, "USD"), want: "0.05 USD"},
+		{in: New(-105, ""), want: "-1.05 CNY"},
+		{in: Money{}, want: "0.00 CNY"},
+		{in: New(math.MinInt64, ""), want: "-92233720368547758.08 CNY"},
+	}
+	for _, tt := range tests {
+		if got := tt.in.String(); got != tt.want {
+			t.Errorf("%+v.String() = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	sum, err := New(150, "").Add(New(250, ""))
+	if err != nil || sum != New(400, "") {
+		t.Fatalf("Add = %+v, %v, want 4.00 CNY", sum, err)
+	}
+	//没有写币种的金额按人民币计算
+	sum, err = Money{Minor: 1}.Add(New(1, "CNY"))
+	if err != nil || sum != New(2, "CNY") {
+		t.Fatalf("Add without currency = %+v, %v, want 0.02 CNY", sum, err)
+	}
+	diff, err := New(100, "").Sub(New(250, ""))
+	if err != nil || diff != New(-150, "") {
+		t.Fatalf("Sub = %+v, %v, want -1.50 CNY", diff, err)
+	}
+
+	if _, err := New(math.MaxInt64, "").Add(New(1, "")); err != ErrOverflow {
+		t.Errorf("Add past MaxInt64 error = %v, want %v", err, ErrOverflow)
+	}
+	if _, err := New(math.MinInt64, "").Add(New(-1, "")); err != ErrOverflow {
+		t.Errorf("Add past MinInt64 error = %v, want %v", err, ErrOverflow)
+	}
+	if _, err := New(0, "").Sub(New(math.MinInt64, "")); err != ErrOverflow {
+		t.Errorf("Sub of MinInt64 error = %v, want %v", err, ErrOverflow)
+	}
+}
+
+func TestCurrencyMismatch(t *testing.T) {
+	cny := New(100, "CNY")
+	usd := New(100, "USD")
+	if _, err := cny.Add(usd); err != ErrCurrencyMismatch {
+		t.Errorf("Add error = %v, want %v", err, ErrCurrencyMismatch)
+	}
+	if _, err := cny.Sub(usd); err != ErrCurrencyMismatch {
+		t.Errorf("Sub error = %v, want %v", err, ErrCurrencyMismatch)
+	}
+	if _, err := cny.Cmp(usd); err != ErrCurrencyMismatch {
+		t.Errorf("Cmp error = %v, want %v", err, ErrCurrencyMismatch)
+	}
+	if cny.Equal(usd) {
+		t.Errorf("%v and %v are equal", cny, usd)
+	}
+	if c, err := cny.Cmp(New(99, "")); err != nil || c != 1 {
+		t.Errorf("Cmp = %d, %v, want 1", c, err)
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		in string
+		want Money
+		wantErr bool
+	}{
+		{name: "old integer string", in: `"1000000"`, want: New(100000000, "")},
+		{name: "old empty string", in: `""`, want: New(0, "")},
+		{name: "canonical string", in: `"1234.50 USD"`, want: New(123450, "USD")},
+		{name: "json number", in: `12.5`, want: New(1250, "")},
+		{name: "old amount with three decimals", in: `"1.005"`, wantErr: true},
+		{name: "not a string or number", in: `true`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Money
+			err := json.Unmarshal([]byte(tt.in), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal(%s) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Fatalf("Unmarshal(%s) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+
+	//保存后再读出来金额和币种不变
+	b, err := json.Marshal(New(-123456, "USD"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `"-1234.56 USD"` {
+		t.Fatalf("Marshal = %s", b)
+	}
+	var back Money
+	if err := json.Unmarshal(b, &back); err != nil || back != New(-123456, "USD") {
+		t.Fatalf("round trip = %+v, %v", back, err)
+	}
+}